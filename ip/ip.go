@@ -5,6 +5,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/sebest/xff"
 )
@@ -23,3 +24,26 @@ func MustGetPublicFromRequest(r *http.Request) string {
 
 	return ip
 }
+
+// FromTrustedRequest returns ip of the client, X-Forwarded-For is followed from the right
+// only while the address is accepted by trusted, so addresses added by client are ignored.
+// Without trusted the header is ignored and ip of the peer is returned.
+func FromTrustedRequest(r *http.Request, trusted func(ip string) bool) string {
+	addr := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	if trusted == nil {
+		return addr
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0 && trusted(addr); i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		addr = hop
+	}
+	return addr
+}
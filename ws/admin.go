@@ -0,0 +1,173 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ConnectionInfo describes live connection
+type ConnectionInfo struct {
+	ID          string            `json:"id"`
+	RemoteIP    string            `json:"remote_ip"`
+	Principal   string            `json:"principal,omitempty"`
+	ConnectedAt time.Time         `json:"connected_at"`
+	LastMessage *time.Time        `json:"last_message,omitempty"`
+	Rooms       []string          `json:"rooms"`
+	QueuedBytes int64             `json:"queued_bytes"`
+	Traffic     Traffic           `json:"traffic"`
+	Values      map[string]string `json:"values,omitempty"`
+}
+
+// Info returns description of the connection
+func (conn *Connection) Info() ConnectionInfo {
+	info := ConnectionInfo{
+		ID:          conn.id,
+		Principal:   conn.principal,
+		ConnectedAt: conn.connectedAt,
+		Rooms:       conn.Rooms(),
+		QueuedBytes: atomic.LoadInt64(&conn.queued),
+		Traffic:     conn.Traffic(),
+		Values:      conn.DebugValues(),
+	}
+	if conn.Request != nil {
+		info.RemoteIP = conn.ws.clientIP(conn.Request)
+	}
+	if last := atomic.LoadInt64(&conn.lastMessage); last != 0 {
+		t := time.Unix(0, last)
+		info.LastMessage = &t
+	}
+	return info
+}
+
+// Admin returns handler for operators of the server:
+//
+//	GET  .../connections              lists live connections
+//	GET  .../topics                   lists stats of topics
+//	POST .../connections/{id}/close   closes the connection, body {"code": 1001, "reason": "..."} is optional
+//	POST .../broadcast                sends {"room": "...", "topic": "...", "data": ...}, to every connection if room is empty
+//
+// Requests are rejected with 403 unless authorize returns true.
+func (m *ws) Admin(authorize func(r *http.Request) bool) http.Handler {
+	return &admin{m: m, authorize: authorize}
+}
+
+type admin struct {
+	m         *ws
+	authorize func(r *http.Request) bool
+}
+
+func (a *admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if a.authorize == nil || !a.authorize(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/connections"):
+		writeJSON(w, a.m.connections())
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/topics"):
+		writeJSON(w, a.m.stats.list())
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/close") && strings.Contains(path, "/connections/"):
+		a.close(w, r, path)
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/broadcast"):
+		a.broadcast(w, r)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// connections returns info of connections served by this instance sorted by connection time
+func (m *ws) connections() []ConnectionInfo {
+	m.mu.RLock()
+	conns := make([]*Connection, 0, len(m.conns))
+	for _, conn := range m.conns {
+		conns = append(conns, conn)
+	}
+	m.mu.RUnlock()
+
+	infos := make([]ConnectionInfo, 0, len(conns))
+	for _, conn := range conns {
+		infos = append(infos, conn.Info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ConnectedAt.Before(infos[j].ConnectedAt)
+	})
+	return infos
+}
+
+type adminClose struct {
+	Code   int    `json:"code"`
+	Reason string `json:"reason"`
+}
+
+func (a *admin) close(w http.ResponseWriter, r *http.Request, path string) {
+	id := strings.TrimSuffix(path[strings.LastIndex(path, "/connections/")+len("/connections/"):], "/close")
+
+	a.m.mu.RLock()
+	conn, ok := a.m.conns[id]
+	a.m.mu.RUnlock()
+	if !ok {
+		http.Error(w, "Unknown connection", http.StatusNotFound)
+		return
+	}
+
+	req := adminClose{Code: websocket.CloseGoingAway, Reason: "closed by administrator"}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid body", http.StatusBadRequest)
+			return
+		}
+	}
+	if !validCloseCode(req.Code) {
+		http.Error(w, "Invalid close code", http.StatusBadRequest)
+		return
+	}
+
+	conn.Close(req.Code, req.Reason)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validCloseCode reports whether code may be sent in close frame,
+// codes reserved for local use (1005, 1006) and unassigned codes are not
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code >= websocket.CloseNormalClosure && code <= websocket.CloseTryAgainLater:
+		return code != 1004 && code != websocket.CloseNoStatusReceived && code != websocket.CloseAbnormalClosure
+	default:
+		return false
+	}
+}
+
+type adminBroadcast struct {
+	Room  string          `json:"room"`
+	Topic string          `json:"topic"`
+	Data  json.RawMessage `json:"data"`
+}
+
+func (a *admin) broadcast(w http.ResponseWriter, r *http.Request) {
+	var req adminBroadcast
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Topic == "" {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+
+	if err := a.m.Broadcast(req.Room, req.Topic, req.Data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,85 @@
+package ws_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/wstest"
+	"github.com/gorilla/websocket"
+)
+
+func TestAdmin(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	w.RegisterHandler("room.{id}.join", func(r *ws.Request) {
+		r.C.Join(r.Param("id"))
+		r.Respond(r.C.ID())
+	})
+
+	admin := w.Admin(func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "admin"
+	})
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "admin")
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := httptest.NewRecorder()
+	admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/connections", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("unauthorized: status %d; want %d", rec.Code, http.StatusForbidden)
+	}
+
+	c := wstest.Connect(t, w)
+	var id string
+	c.Call("room.lobby.join", nil, &id)
+
+	var conns []ws.ConnectionInfo
+	json.NewDecoder(serve(http.MethodGet, "/admin/connections", "").Body).Decode(&conns)
+	if len(conns) != 1 || conns[0].ID != id || len(conns[0].Rooms) != 1 || conns[0].Rooms[0] != "lobby" {
+		t.Fatalf("connections %+v; want %s in lobby", conns, id)
+	}
+	if conns[0].LastMessage == nil {
+		t.Errorf("last message not set")
+	}
+
+	// requests are read one by one, so the first is counted once the second is answered
+	c.Call("room.lobby.join", nil, &id)
+
+	var topics []ws.TopicStats
+	json.NewDecoder(serve(http.MethodGet, "/admin/topics", "").Body).Decode(&topics)
+	if len(topics) != 1 || topics[0].Topic != "room.{id}.join" || topics[0].Requests < 1 {
+		t.Errorf("topics %+v; want single request of room.{id}.join", topics)
+	}
+
+	// in-memory sockets are unbuffered, so broadcast must run concurrently with reads
+	done := make(chan int, 1)
+	go func() {
+		done <- serve(http.MethodPost, "/admin/broadcast", `{"topic": "maintenance", "data": "restart"}`).Code
+	}()
+	var msg string
+	c.ExpectPush("maintenance", &msg)
+	if msg != "restart" {
+		t.Errorf("broadcast %q; want restart", msg)
+	}
+	if code := <-done; code != http.StatusNoContent {
+		t.Errorf("broadcast: status %d; want %d", code, http.StatusNoContent)
+	}
+
+	if rec := serve(http.MethodPost, "/admin/connections/missing/close", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("close missing: status %d; want %d", rec.Code, http.StatusNotFound)
+	}
+	for _, code := range []string{"0", "1005", "1006", "5000"} {
+		if rec := serve(http.MethodPost, "/admin/connections/"+id+"/close", `{"code": `+code+`}`); rec.Code != http.StatusBadRequest {
+			t.Errorf("close with %s: status %d; want %d", code, rec.Code, http.StatusBadRequest)
+		}
+	}
+	go serve(http.MethodPost, "/admin/connections/"+id+"/close", "")
+	c.ExpectClose(websocket.CloseGoingAway)
+}
@@ -0,0 +1,86 @@
+package ws_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/client"
+	"github.com/IAmRadek/go-kit/ws/wstest"
+	memws "github.com/posener/wstest"
+)
+
+func TestBatch(t *testing.T) {
+	for _, opts := range []ws.BatchOptions{{}, {Concurrency: 4, Combine: true}} {
+		w := ws.NewWS(nil, func(connection *ws.Connection, err error) {}, ws.WithBatch(opts))
+		w.RegisterHandler("double", func(r *ws.Request, n int) {
+			r.Respond(n * 2)
+		})
+
+		c := wstest.Connect(t, w)
+
+		var a, b int
+		calls := []*client.BatchCall{
+			{Topic: "double", Payload: 1, Out: &a},
+			{Topic: "missing"},
+			{Topic: "double", Payload: 2, Out: &b},
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), wstest.Timeout)
+		if err := c.Batch(ctx, calls...); err != nil {
+			t.Fatalf("%+v: %v", opts, err)
+		}
+		cancel()
+
+		if a != 2 || b != 4 {
+			t.Errorf("%+v: got %d %d; want 2 4", opts, a, b)
+		}
+		if wsErr, ok := calls[1].Err.(*ws.Error); !ok || wsErr.Code != ws.CodeUnknownTopic {
+			t.Errorf("%+v: got %v; want unknown topic", opts, calls[1].Err)
+		}
+
+		// invalid item is answered with error without closing connection
+		c.Raw([]byte(`[{"ID": 10, "Topic": "double", "Data": 5}, {"ID": 11}]`))
+		var got int
+		c.Call("double", 3, &got)
+		if got != 6 {
+			t.Errorf("%+v: got %d; want 6", opts, got)
+		}
+	}
+}
+
+func TestBatchCombined(t *testing.T) {
+	var running, peak int32
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {},
+		ws.WithBatch(ws.BatchOptions{Concurrency: 2, Combine: true}))
+	w.RegisterHandler("double", func(r *ws.Request, n int) {
+		cur := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if cur <= old || atomic.CompareAndSwapInt32(&peak, old, cur) {
+				break
+			}
+		}
+		r.Respond(n * 2)
+	})
+
+	conn, _, err := memws.NewDialer(w).Dial("ws://example.org/websocket", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.WriteJSON([]packet{{1, "double", 1}, {2, "double", 2}, {3, "double", 3}})
+
+	var resps []errorPacket
+	if err := conn.ReadJSON(&resps); err != nil {
+		t.Fatal(err)
+	}
+	if len(resps) != 3 {
+		t.Errorf("got %d responses; want 3", len(resps))
+	}
+	if p := atomic.LoadInt32(&peak); p > 2 {
+		t.Errorf("%d requests dispatched concurrently; want at most 2", p)
+	}
+}
@@ -0,0 +1,193 @@
+package ws
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Binary frames carry request ID as 8 bytes big-endian integer followed by data.
+// Streams of binary frames, see Request.Upload and Request.Download, end with frame without data.
+// Binary frames are supported only by websocket endpoints.
+const binaryHeaderSize = 8
+
+var (
+	ErrBinaryUnsupported = errors.New("ws: binary frames are not supported by transport")
+	ErrDownloadTooLarge  = errors.New("ws: download too large")
+	ErrUnknownUpload     = errors.New("ws: binary frame without upload")
+)
+
+// ErrUploadTooLarge is read from upload exceeding UploadOptions.MaxSize
+var ErrUploadTooLarge = &Error{Code: CodeInvalidPayload, Message: "upload too large"}
+
+// BinaryFrame returns binary frame carrying data of the request
+func BinaryFrame(id int64, data []byte) []byte {
+	frame := make([]byte, binaryHeaderSize+len(data))
+	binary.BigEndian.PutUint64(frame, uint64(id))
+	copy(frame[binaryHeaderSize:], data)
+	return frame
+}
+
+// ParseBinaryFrame returns request ID and data of binary frame
+func ParseBinaryFrame(frame []byte) (int64, []byte, error) {
+	if len(frame) < binaryHeaderSize {
+		return 0, nil, ErrInvalidPacket
+	}
+	return int64(binary.BigEndian.Uint64(frame)), frame[binaryHeaderSize:], nil
+}
+
+// SendBinary sends binary frame with data associated with request ID
+func (conn *Connection) SendBinary(id int64, data []byte) error {
+	frame := BinaryFrame(id, data)
+	if !conn.ws.account(conn, Outbound, len(frame)) {
+		return ErrQuotaExceeded
+	}
+	conn.observeBinary(Outbound, frame)
+
+	atomic.AddInt64(&conn.queued, int64(len(frame)))
+	defer atomic.AddInt64(&conn.queued, -int64(len(frame)))
+	return conn.transport.writeBinary(frame)
+}
+
+// UploadOptions configures Request.Upload
+type UploadOptions struct {
+	// MaxSize limits bytes of the upload, 10MB by default
+	MaxSize int64
+	// Progress is called with bytes received so far after every frame
+	Progress func(received int64)
+}
+
+// upload passes data of binary frames to reader of the upload
+type upload struct {
+	w        *io.PipeWriter
+	opts     UploadOptions
+	received int64
+}
+
+// Upload receives data of binary frames sent by client with the request ID, until frame without data.
+// fn reads the data in separate goroutine and its result is sent as response, or error if it fails.
+// Reading the connection waits while fn does not read, so fn must consume the reader.
+func (r *Request) Upload(opts UploadOptions, fn func(data io.Reader) (interface{}, error)) {
+	if opts.MaxSize == 0 {
+		opts.MaxSize = 10 << 20
+	}
+
+	pr, pw := io.Pipe()
+	conn := r.C
+	conn.ul.Lock()
+	if conn.uploads == nil {
+		conn.uploads = make(map[int64]*upload)
+	}
+	conn.uploads[r.ID] = &upload{w: pw, opts: opts}
+	conn.ul.Unlock()
+
+	go func() {
+		resp, err := fn(pr)
+		pr.CloseWithError(ErrSessionClosed)
+
+		conn.ul.Lock()
+		delete(conn.uploads, r.ID)
+		conn.ul.Unlock()
+
+		if err != nil {
+			r.Fail(err)
+			return
+		}
+		r.Respond(resp)
+	}()
+}
+
+// handleBinary passes data of binary frame to upload of its request
+func (m *ws) handleBinary(conn *Connection, frame []byte) error {
+	atomic.StoreInt64(&conn.lastMessage, time.Now().UnixNano())
+	if !m.account(conn, Inbound, len(frame)) {
+		return ErrQuotaExceeded
+	}
+	conn.observeBinary(Inbound, frame)
+
+	id, data, err := ParseBinaryFrame(frame)
+	if err != nil {
+		m.reportError(conn, err)
+		return nil
+	}
+
+	conn.ul.Lock()
+	u, ok := conn.uploads[id]
+	conn.ul.Unlock()
+	if !ok {
+		m.reportError(conn, fmt.Errorf("%w: %d", ErrUnknownUpload, id))
+		return nil
+	}
+
+	if len(data) == 0 {
+		u.w.Close()
+		return nil
+	}
+	if u.received+int64(len(data)) > u.opts.MaxSize {
+		u.w.CloseWithError(ErrUploadTooLarge)
+		return nil
+	}
+
+	// write fails once reader is closed, remaining frames of the upload are dropped
+	if _, err := u.w.Write(data); err == nil {
+		u.received += int64(len(data))
+		if u.opts.Progress != nil {
+			u.opts.Progress(u.received)
+		}
+	}
+	return nil
+}
+
+// cancelUploads is called when connection is closed
+func (conn *Connection) cancelUploads() {
+	conn.ul.Lock()
+	defer conn.ul.Unlock()
+	for _, u := range conn.uploads {
+		u.w.CloseWithError(ErrSessionClosed)
+	}
+}
+
+// DownloadOptions configures Request.Download
+type DownloadOptions struct {
+	// ChunkSize is size of data of single binary frame, 64KB by default
+	ChunkSize int
+	// MaxSize limits bytes of the download, unlimited if zero
+	MaxSize int64
+	// Progress is called with bytes sent so far after every frame
+	Progress func(sent int64)
+}
+
+// Download sends data read from src as binary frames with the request ID, followed by frame without data.
+// Download which fails is not finished, so the handler should return the error to client.
+func (r *Request) Download(src io.Reader, opts DownloadOptions) error {
+	if opts.ChunkSize == 0 {
+		opts.ChunkSize = 64 << 10
+	}
+
+	buf := make([]byte, opts.ChunkSize)
+	var sent int64
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if opts.MaxSize > 0 && sent+int64(n) > opts.MaxSize {
+				return ErrDownloadTooLarge
+			}
+			if err := r.C.SendBinary(r.ID, buf[:n]); err != nil {
+				return err
+			}
+			sent += int64(n)
+			if opts.Progress != nil {
+				opts.Progress(sent)
+			}
+		}
+		if err == io.EOF {
+			return r.C.SendBinary(r.ID, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package ws_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/wstest"
+)
+
+func TestBinaryTransfer(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+
+	progress := make(chan int64, 100)
+	w.RegisterHandler("avatar.upload", func(r *ws.Request, name string) {
+		r.Upload(ws.UploadOptions{MaxSize: 1000, Progress: func(received int64) {
+			progress <- received
+		}}, func(data io.Reader) (interface{}, error) {
+			b, err := io.ReadAll(data)
+			return len(b), err
+		})
+	})
+
+	export := bytes.Repeat([]byte("0123456789"), 50)
+	w.RegisterHandler("export", func(r *ws.Request) error {
+		return r.Download(bytes.NewReader(export), ws.DownloadOptions{ChunkSize: 128})
+	})
+	w.RegisterHandler("export.limited", func(r *ws.Request) error {
+		return r.Download(bytes.NewReader(export), ws.DownloadOptions{ChunkSize: 128, MaxSize: 200})
+	})
+
+	c := wstest.Connect(t, w)
+	ctx, cancel := context.WithTimeout(context.Background(), wstest.Timeout)
+	defer cancel()
+
+	var size int
+	if err := c.Upload(ctx, "avatar.upload", "me.png", bytes.NewReader(make([]byte, 900)), 100, &size); err != nil {
+		t.Fatal(err)
+	}
+	if size != 900 {
+		t.Errorf("uploaded %d bytes; want 900", size)
+	}
+	if len(progress) != 9 {
+		t.Errorf("got %d progress reports; want 9", len(progress))
+	}
+
+	var wsErr *ws.Error
+	err := c.Upload(ctx, "avatar.upload", "big.png", bytes.NewReader(make([]byte, 2000)), 100, &size)
+	if !errors.As(err, &wsErr) || wsErr.Message != "upload too large" {
+		t.Errorf("got %v; want upload too large", err)
+	}
+
+	var buf bytes.Buffer
+	if err := c.Download(ctx, "export", nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), export) {
+		t.Errorf("downloaded %d bytes; want %d", buf.Len(), len(export))
+	}
+
+	buf.Reset()
+	if err := c.Download(ctx, "export.limited", nil, &buf); !errors.As(err, &wsErr) || wsErr.Code != ws.CodeInternal {
+		t.Errorf("got %v; want internal error", err)
+	}
+
+	// connection still serves requests
+	var got int
+	c.Upload(ctx, "avatar.upload", "small.png", bytes.NewReader(make([]byte, 10)), 0, &got)
+	if got != 10 {
+		t.Errorf("uploaded %d bytes; want 10", got)
+	}
+}
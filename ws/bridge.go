@@ -0,0 +1,167 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// HTTPBridge returns handler invoking registered topics over plain HTTP:
+//
+//	POST .../topic/{name}
+//
+// Request body is the payload, response body is the data passed to Request.Respond
+// or the Error. Every HTTP request is served as connection with single frame,
+// so it is admitted by Limits, passed to lifecycles and hooks, counted in traffic
+// and handled with the same middleware as websocket requests.
+// The connection is closed with 1000 (normal closure) once the request is handled,
+// pushes to it are dropped and rejection by Lifecycle.OnConnect is answered
+// with 403 Forbidden and the close reason.
+// Idempotency-Key header sets Request.IdempotencyKey, see Dedup.
+func (m *ws) HTTPBridge() http.Handler {
+	return http.HandlerFunc(m.serveBridge)
+}
+
+// bridgeID is ID of the request of the bridge connection
+const bridgeID = 1
+
+// bridgeTransport keeps response to the request of the bridge connection and drops pushes
+type bridgeTransport struct {
+	mu   sync.Mutex
+	resp *bridgeResponse
+}
+
+type bridgeResponse struct {
+	ID    int64
+	Data  json.RawMessage
+	Error *Error
+}
+
+func (t *bridgeTransport) write(topic string, frame []byte) error {
+	var resp bridgeResponse
+	if err := json.Unmarshal(frame, &resp); err != nil || resp.ID != bridgeID {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.resp == nil {
+		t.resp = &resp
+	}
+	return nil
+}
+
+func (t *bridgeTransport) writeBinary(frame []byte) error {
+	return ErrBinaryUnsupported
+}
+
+func (t *bridgeTransport) close(code int, reason string) error {
+	return nil
+}
+
+func (t *bridgeTransport) response() *bridgeResponse {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.resp
+}
+
+func (m *ws) serveBridge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	i := strings.LastIndex(r.URL.Path, "/topic/")
+	if i < 0 || i+len("/topic/") == len(r.URL.Path) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	topic := r.URL.Path[i+len("/topic/"):]
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, m.fallbackOpts.withDefaults().MaxFrameSize))
+	if err != nil {
+		http.Error(w, "Request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if len(body) > 0 && !json.Valid(body) {
+		writeBridgeError(w, &Error{Code: CodeInvalidPayload, Message: "invalid payload"})
+		return
+	}
+	frame, err := json.Marshal(struct {
+		ID             int64
+		Topic          string
+		Data           json.RawMessage `json:",omitempty"`
+		IdempotencyKey string          `json:",omitempty"`
+	}{bridgeID, topic, body, r.Header.Get("Idempotency-Key")})
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	release, status := m.admit(r)
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	t := &bridgeTransport{}
+	conn := m.newConnection(ctx, r, t, nativeCodec{})
+	read := false
+	m.run(conn, func() ([]byte, error) {
+		if !read {
+			read = true
+			return frame, nil
+		}
+		conn.Close(websocket.CloseNormalClosure, "")
+		return nil, &websocket.CloseError{Code: websocket.CloseNormalClosure}
+	})
+
+	resp := t.response()
+	if resp == nil {
+		if code, reason, _ := conn.closeStatus(nil); code != websocket.CloseNormalClosure {
+			http.Error(w, reason, http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if resp.Error != nil {
+		writeBridgeError(w, resp.Error)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp.Data)
+}
+
+func writeBridgeError(w http.ResponseWriter, e *Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(bridgeStatus(e.Code))
+	json.NewEncoder(w).Encode(e)
+}
+
+func bridgeStatus(code ErrorCode) int {
+	switch code {
+	case CodeUnknownTopic:
+		return http.StatusNotFound
+	case CodeInvalidPayload:
+		return http.StatusBadRequest
+	case CodeUnauthorized:
+		return http.StatusUnauthorized
+	case CodeRateLimited:
+		return http.StatusTooManyRequests
+	case CodeInternal:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,107 @@
+package ws_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/IAmRadek/go-kit/ws"
+)
+
+func TestHTTPBridge(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	w.Use(func(next ws.HandlerFunc) ws.HandlerFunc {
+		return func(r *ws.Request) error {
+			if r.C.Request.Header.Get("Authorization") == "" {
+				return ws.ErrUnauthorized
+			}
+			return next(r)
+		}
+	})
+	w.RegisterHandler("greet", func(r *ws.Request, name string) {
+		r.Respond("hello " + name)
+	})
+	w.RegisterHandler("noop", func(r *ws.Request) {})
+
+	bridge := w.HTTPBridge()
+
+	tests := []struct {
+		Path   string
+		Body   string
+		Auth   bool
+		Status int
+		Resp   string
+	}{
+		{"/topic/greet", `"alice"`, true, http.StatusOK, `"hello alice"`},
+		{"/topic/greet", `"alice"`, false, http.StatusUnauthorized, `{"Code":"unauthorized","Message":"unauthorized"}`},
+		{"/topic/greet", `1`, true, http.StatusBadRequest, ``},
+		{"/topic/missing", ``, true, http.StatusNotFound, `{"Code":"unknown_topic","Message":"unknown topic"}`},
+		{"/topic/noop", ``, true, http.StatusNoContent, ``},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, test.Path, strings.NewReader(test.Body))
+		if test.Auth {
+			req.Header.Set("Authorization", "token")
+		}
+		rec := httptest.NewRecorder()
+		bridge.ServeHTTP(rec, req)
+
+		if rec.Code != test.Status {
+			t.Errorf("%s %s: status %d; want %d", test.Path, test.Body, rec.Code, test.Status)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); test.Resp != "" && got != test.Resp {
+			t.Errorf("%s %s: body %s; want %s", test.Path, test.Body, got, test.Resp)
+		}
+	}
+}
+
+func TestHTTPBridgeLifecycle(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	w.AddLifecycle(ws.LifecycleFuncs{
+		Connect: func(conn *ws.Connection) error {
+			if conn.Request.Header.Get("Authorization") == "" {
+				return errors.New("not logged in")
+			}
+			return nil
+		},
+	})
+	var hooked int
+	w.AddPreHook(func(conn *ws.Connection) { hooked++ })
+	w.RegisterHandler("greet", func(r *ws.Request, name string) {
+		r.Respond("hello " + name)
+	})
+
+	bridge := w.HTTPBridge()
+
+	tests := []struct {
+		Auth   bool
+		Status int
+		Resp   string
+		Hooked int
+	}{
+		{false, http.StatusForbidden, "not logged in", 0},
+		{true, http.StatusOK, `"hello alice"`, 1},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/topic/greet", strings.NewReader(`"alice"`))
+		if test.Auth {
+			req.Header.Set("Authorization", "token")
+		}
+		rec := httptest.NewRecorder()
+		bridge.ServeHTTP(rec, req)
+
+		if rec.Code != test.Status {
+			t.Errorf("auth %t: status %d; want %d", test.Auth, rec.Code, test.Status)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != test.Resp {
+			t.Errorf("auth %t: body %s; want %s", test.Auth, got, test.Resp)
+		}
+		if hooked != test.Hooked {
+			t.Errorf("auth %t: pre hook called %d times; want %d", test.Auth, hooked, test.Hooked)
+		}
+	}
+}
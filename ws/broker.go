@@ -0,0 +1,225 @@
+package ws
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Message is a broadcast or targeted send propagated between instances
+type Message struct {
+	// Origin is identifier of instance which published the message
+	Origin string
+	// Room is set for broadcasts
+	Room string `json:",omitempty"`
+	// Conn is set for sends targeted to single connection
+	Conn string `json:",omitempty"`
+	// Channel is set for events published to subscriptions
+	Channel string `json:",omitempty"`
+
+	Topic string
+	Data  json.RawMessage
+}
+
+// Broker propagates messages between WS instances,
+// e.g. replicas of the same service running behind load balancer
+type Broker interface {
+	// Publish sends message to every subscriber, including the publishing one
+	Publish(msg Message) error
+
+	// Subscribe registers fn to be called for every published message
+	Subscribe(fn func(msg Message)) (cancel func(), err error)
+}
+
+// MemoryBroker is in-process Broker, useful for tests
+// and for running several WS instances in single process
+type MemoryBroker struct {
+	mu   sync.RWMutex
+	next int
+	subs map[int]func(Message)
+}
+
+// NewMemoryBroker returns empty MemoryBroker
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{subs: make(map[int]func(Message))}
+}
+
+// Publish calls every subscriber synchronously
+func (b *MemoryBroker) Publish(msg Message) error {
+	b.mu.RLock()
+	subs := make([]func(Message), 0, len(b.subs))
+	for _, fn := range b.subs {
+		subs = append(subs, fn)
+	}
+	b.mu.RUnlock()
+
+	for _, fn := range subs {
+		fn(msg)
+	}
+	return nil
+}
+
+// Subscribe registers fn to be called for every published message
+func (b *MemoryBroker) Subscribe(fn func(msg Message)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.next
+	b.next++
+	b.subs[id] = fn
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, id)
+	}, nil
+}
+
+func (m *ws) register(conn *Connection) {
+	m.connectPrincipal(conn)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.conns[conn.id] = conn
+}
+
+func (m *ws) unregister(conn *Connection) {
+	m.disconnectPrincipal(conn)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.conns, conn.id)
+	for room := range conn.rooms {
+		m.removeFromRoom(conn, room)
+	}
+}
+
+func (m *ws) join(conn *Connection, room string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.conns[conn.id]; !ok {
+		return
+	}
+	members, ok := m.rooms[room]
+	if !ok {
+		members = make(map[*Connection]struct{})
+		m.rooms[room] = members
+	}
+	members[conn] = struct{}{}
+	conn.rooms[room] = struct{}{}
+}
+
+func (m *ws) leave(conn *Connection, room string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removeFromRoom(conn, room)
+}
+
+// removeFromRoom must be called with m.mu held
+func (m *ws) removeFromRoom(conn *Connection, room string) {
+	delete(conn.rooms, room)
+	members := m.rooms[room]
+	delete(members, conn)
+	if len(members) == 0 {
+		delete(m.rooms, room)
+		if m.sequences != nil {
+			delete(m.sequences.rooms, room)
+		}
+	}
+}
+
+// Broadcast sends data to every connection which joined the room,
+// to every connection if room is empty
+func (m *ws) Broadcast(room string, topic string, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	msg := Message{Origin: m.id, Room: room, Topic: topic, Data: raw}
+	m.deliver(msg)
+
+	if m.broker != nil {
+		return m.broker.Publish(msg)
+	}
+	return nil
+}
+
+// SendTo sends data to the connection with entered id
+func (m *ws) SendTo(connID string, topic string, data interface{}) error {
+	m.mu.RLock()
+	conn, ok := m.conns[connID]
+	m.mu.RUnlock()
+	if ok {
+		return conn.Send(0, topic, data)
+	}
+
+	if m.broker == nil {
+		return ErrUnknownConnection
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return m.broker.Publish(Message{Origin: m.id, Conn: connID, Topic: topic, Data: raw})
+}
+
+// receive delivers messages published by other instances
+func (m *ws) receive(msg Message) {
+	if msg.Origin == m.id {
+		return
+	}
+	m.deliver(msg)
+}
+
+func (m *ws) deliver(msg Message) {
+	if msg.Channel != "" {
+		m.publishLocal(msg.Channel, msg.Data)
+		return
+	}
+
+	var targets []*Connection
+
+	m.mu.RLock()
+	switch {
+	case msg.Room != "":
+		for conn := range m.rooms[msg.Room] {
+			targets = append(targets, conn)
+		}
+	case msg.Conn == "":
+		// broadcast without room reaches every connection
+		for _, conn := range m.conns {
+			targets = append(targets, conn)
+		}
+	}
+	if conn, ok := m.conns[msg.Conn]; ok && msg.Conn != "" {
+		targets = append(targets, conn)
+	}
+	m.mu.RUnlock()
+
+	if msg.Room != "" {
+		m.mu.Lock()
+		s := m.roomSequence(msg.Room)
+		m.mu.Unlock()
+		if s != nil {
+			err := s.send("room:"+msg.Room, response{Topic: msg.Topic, Data: msg.Data}, func(resp response) error {
+				for _, conn := range targets {
+					conn.enqueue(resp)
+				}
+				return nil
+			})
+			if err != nil {
+				for _, conn := range targets {
+					m.reportError(conn, err)
+				}
+			}
+			return
+		}
+	}
+
+	for _, conn := range targets {
+		if err := conn.Send(0, msg.Topic, msg.Data); err != nil {
+			m.reportError(conn, err)
+		}
+	}
+}
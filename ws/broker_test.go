@@ -0,0 +1,130 @@
+package ws_test
+
+import (
+	"testing"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/gorilla/websocket"
+	"github.com/posener/wstest"
+)
+
+func TestBroadcastAcrossInstances(t *testing.T) {
+	broker := ws.NewMemoryBroker()
+
+	instanceA := ws.NewWS(nil, func(connection *ws.Connection, err error) {}, ws.WithBroker(broker))
+	instanceB := ws.NewWS(nil, func(connection *ws.Connection, err error) {}, ws.WithBroker(broker))
+
+	for _, instance := range []ws.WS{instanceA, instanceB} {
+		instance.RegisterHandler("join", func(r *ws.Request, room string) {
+			r.C.Join(room)
+			r.Respond(r.C.ID())
+		})
+	}
+
+	connA, _, err := wstest.NewDialer(instanceA).Dial("ws://example.org/websocket", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connA.Close()
+
+	connB, _, err := wstest.NewDialer(instanceB).Dial("ws://example.org/websocket", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer connB.Close()
+
+	var idA, idB packet
+	connA.WriteJSON(packet{1, "join", "lobby"})
+	connA.ReadJSON(&idA)
+	connB.WriteJSON(packet{1, "join", "lobby"})
+	connB.ReadJSON(&idB)
+
+	// in-memory sockets are unbuffered, so sends must run concurrently with reads
+	broadcastErr := make(chan error, 1)
+	go func() { broadcastErr <- instanceA.Broadcast("lobby", "hello", "everyone") }()
+
+	for _, conn := range []interface{ ReadJSON(interface{}) error }{connA, connB} {
+		var resp packet
+		if err := conn.ReadJSON(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Topic != "hello" || resp.Data != "everyone" {
+			t.Errorf("got %+v; want hello everyone", resp)
+		}
+	}
+	if err := <-broadcastErr; err != nil {
+		t.Fatal(err)
+	}
+
+	sendErr := make(chan error, 1)
+	go func() { sendErr <- instanceA.SendTo(idB.Data.(string), "direct", "B") }()
+
+	var resp packet
+	if err := connB.ReadJSON(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Topic != "direct" || resp.Data != "B" {
+		t.Errorf("got %+v; want direct B", resp)
+	}
+	if err := <-sendErr; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBroadcastWithoutRoom(t *testing.T) {
+	broker := ws.NewMemoryBroker()
+
+	instanceA := ws.NewWS(nil, func(connection *ws.Connection, err error) {}, ws.WithBroker(broker))
+	instanceB := ws.NewWS(nil, func(connection *ws.Connection, err error) {}, ws.WithBroker(broker))
+
+	for _, instance := range []ws.WS{instanceA, instanceB} {
+		instance.RegisterHandler("ping", func(r *ws.Request) {
+			r.Respond("pong")
+		})
+	}
+
+	var conns []*websocket.Conn
+	for _, instance := range []ws.WS{instanceA, instanceA, instanceB} {
+		conn, _, err := wstest.NewDialer(instance).Dial("ws://example.org/websocket", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		// connection is registered once it handles request
+		var pong packet
+		conn.WriteJSON(packet{1, "ping", nil})
+		conn.ReadJSON(&pong)
+		conns = append(conns, conn)
+	}
+
+	// connections without rooms on every instance receive it
+	broadcastErr := make(chan error, 1)
+	go func() { broadcastErr <- instanceA.Broadcast("", "maintenance", "restart") }()
+
+	// connections on the same instance are sent to in any order
+	received := make(chan packet, len(conns))
+	for _, conn := range conns {
+		go func(conn *websocket.Conn) {
+			var resp packet
+			conn.ReadJSON(&resp)
+			received <- resp
+		}(conn)
+	}
+	for range conns {
+		if resp := <-received; resp.Topic != "maintenance" || resp.Data != "restart" {
+			t.Errorf("got %+v; want maintenance restart", resp)
+		}
+	}
+	if err := <-broadcastErr; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSendToUnknownConnection(t *testing.T) {
+	instance := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+
+	if err := instance.SendTo("missing", "topic", nil); err != ws.ErrUnknownConnection {
+		t.Errorf("SendTo() = %v; want %v", err, ws.ErrUnknownConnection)
+	}
+}
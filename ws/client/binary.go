@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"io"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/gorilla/websocket"
+)
+
+// stream receives data of binary frames of single request
+type stream struct {
+	data chan []byte
+	done chan struct{}
+}
+
+func (c *Client) receiveBinary(frame []byte) {
+	id, data, err := ws.ParseBinaryFrame(frame)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	s, ok := c.streams[id]
+	c.mu.Unlock()
+	if !ok {
+		// frame nobody waits for is passed to Next like unclaimed responses
+		c.receive(Message{ID: id, Raw: frame, Binary: true})
+		return
+	}
+
+	select {
+	case s.data <- data:
+	case <-s.done:
+	}
+}
+
+func (c *Client) writeBinary(id int64, data []byte) error {
+	c.wl.Lock()
+	defer c.wl.Unlock()
+	return c.conn.WriteMessage(websocket.BinaryMessage, ws.BinaryFrame(id, data))
+}
+
+// Upload sends request and data read from src as binary frames of chunkSize bytes,
+// handled by ws.Request.Upload, and decodes response into out.
+// Upload stops early if server responds before all data is sent.
+func (c *Client) Upload(ctx context.Context, topic string, payload interface{}, src io.Reader, chunkSize int, out interface{}) error {
+	if chunkSize <= 0 {
+		chunkSize = 64 << 10
+	}
+
+	id, resp := c.expect()
+	defer c.forget(id)
+
+	if err := c.write(id, topic, payload); err != nil {
+		return err
+	}
+
+	buf := make([]byte, chunkSize)
+	for len(resp) == 0 {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if err := c.writeBinary(id, buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			if err := c.writeBinary(id, nil); err != nil {
+				return err
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return c.await(ctx, resp, out)
+}
+
+// Download sends request handled by ws.Request.Download and writes received data to dst,
+// error response sent instead of the data is returned as *ws.Error
+func (c *Client) Download(ctx context.Context, topic string, payload interface{}, dst io.Writer) error {
+	id, resp := c.expect()
+	defer c.forget(id)
+
+	s := &stream{data: make(chan []byte, 16), done: make(chan struct{})}
+	c.mu.Lock()
+	c.streams[id] = s
+	c.mu.Unlock()
+
+	defer func() {
+		close(s.done)
+		c.mu.Lock()
+		delete(c.streams, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(id, topic, payload); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case data := <-s.data:
+			if len(data) == 0 {
+				return nil
+			}
+			if _, err := dst.Write(data); err != nil {
+				return err
+			}
+		case msg := <-resp:
+			if msg.Error != nil {
+				return msg.Error
+			}
+			// response is sent after data, so data frames were already received
+			for len(s.data) > 0 {
+				if data := <-s.data; len(data) > 0 {
+					if _, err := dst.Write(data); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		case <-c.done:
+			return c.Err()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
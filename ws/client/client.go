@@ -0,0 +1,375 @@
+// Package client implements Go client of the ws protocol.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/gorilla/websocket"
+)
+
+var ErrClosed = errors.New("client: connection closed")
+
+// DefaultPushBuffer is number of messages buffered for Next by default
+const DefaultPushBuffer = 1024
+
+var _ ws.Caller = (*Client)(nil)
+
+// Message is frame received from server,
+// responses carry ID of the request and pushes carry zero ID
+type Message struct {
+	ID    int64
+	Topic string
+	Data  json.RawMessage
+	Error *ws.Error `json:",omitempty"`
+
+	// Seq numbers pushes of Stream, see ws.WithSequences
+	Seq    int64  `json:",omitempty"`
+	Stream string `json:",omitempty"`
+
+	// Raw is the frame as received
+	Raw json.RawMessage `json:"-"`
+	// Binary reports whether Raw is binary frame not received by Download, ID is its request ID
+	Binary bool `json:"-"`
+}
+
+// Decode unmarshals message data into v
+func (m Message) Decode(v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(m.Data, v)
+}
+
+// Client sends requests and receives responses and pushes over websocket connection
+type Client struct {
+	conn *websocket.Conn
+
+	wl sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan Message
+	streams map[int64]*stream
+	seqs    map[string]int64
+	onGap   func(g Gap)
+	pushes  []Message
+	buffer  int
+	dropped int64
+	notify  chan struct{}
+
+	done chan struct{}
+	err  error
+}
+
+// Dial connects to ws server at url
+func Dial(ctx context.Context, url string, header http.Header) (*Client, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+	if err != nil {
+		return nil, err
+	}
+	return New(conn), nil
+}
+
+// New returns Client using already established connection
+func New(conn *websocket.Conn) *Client {
+	c := &Client{
+		conn:    conn,
+		pending: make(map[int64]chan Message),
+		streams: make(map[int64]*stream),
+		seqs:    make(map[string]int64),
+		buffer:  DefaultPushBuffer,
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go c.read()
+	return c
+}
+
+// Conn returns underlying websocket connection
+func (c *Client) Conn() *websocket.Conn {
+	return c.conn
+}
+
+// Send sends request without waiting for response and returns its ID
+func (c *Client) Send(topic string, payload interface{}) (int64, error) {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.mu.Unlock()
+
+	return id, c.write(id, topic, payload)
+}
+
+// Call sends request and decodes response into out,
+// error responses are returned as *ws.Error
+func (c *Client) Call(ctx context.Context, topic string, payload interface{}, out interface{}) error {
+	id, resp := c.expect()
+	defer c.forget(id)
+
+	if err := c.write(id, topic, payload); err != nil {
+		return err
+	}
+	return c.await(ctx, resp, out)
+}
+
+// expect returns ID of new request and channel receiving its response
+func (c *Client) expect() (int64, chan Message) {
+	resp := make(chan Message, 1)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	c.pending[c.nextID] = resp
+	return c.nextID, resp
+}
+
+func (c *Client) forget(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, id)
+}
+
+// await decodes response into out
+func (c *Client) await(ctx context.Context, resp chan Message, out interface{}) error {
+	select {
+	case msg := <-resp:
+		if msg.Error != nil {
+			return msg.Error
+		}
+		return msg.Decode(out)
+	case <-c.done:
+		return c.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// BatchCall is single request of Client.Batch
+type BatchCall struct {
+	Topic   string
+	Payload interface{}
+	// Out receives decoded response data
+	Out interface{}
+	// Err is set to *ws.Error if server responded with error
+	Err error
+}
+
+// Batch sends calls in single array frame and waits for all responses,
+// errors of individual calls are stored in BatchCall.Err
+func (c *Client) Batch(ctx context.Context, calls ...*BatchCall) error {
+	type request struct {
+		ID    int64
+		Topic string
+		Data  interface{}
+	}
+
+	reqs := make([]request, len(calls))
+	resps := make([]chan Message, len(calls))
+
+	c.mu.Lock()
+	for i, call := range calls {
+		c.nextID++
+		reqs[i] = request{c.nextID, call.Topic, call.Payload}
+		resps[i] = make(chan Message, 1)
+		c.pending[c.nextID] = resps[i]
+	}
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		for _, req := range reqs {
+			delete(c.pending, req.ID)
+		}
+		c.mu.Unlock()
+	}()
+
+	c.wl.Lock()
+	err := c.conn.WriteJSON(reqs)
+	c.wl.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for i, call := range calls {
+		select {
+		case msg := <-resps[i]:
+			if msg.Error != nil {
+				call.Err = msg.Error
+				continue
+			}
+			call.Err = msg.Decode(call.Out)
+		case <-c.done:
+			return c.Err()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// SetPushBuffer sets number of pushes and responses nobody waited for which are buffered for Next,
+// the oldest are dropped when the buffer is full, zero disables buffering for clients which never call Next
+func (c *Client) SetPushBuffer(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.buffer = n
+	if len(c.pushes) > n {
+		c.dropped += int64(len(c.pushes) - n)
+		c.pushes = append([]Message(nil), c.pushes[len(c.pushes)-n:]...)
+	}
+}
+
+// Dropped returns number of messages dropped because push buffer was full
+func (c *Client) Dropped() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
+
+// Next returns next push or response which nobody waited for
+func (c *Client) Next(ctx context.Context) (Message, error) {
+	for {
+		c.mu.Lock()
+		if len(c.pushes) > 0 {
+			msg := c.pushes[0]
+			c.pushes = c.pushes[1:]
+			c.mu.Unlock()
+			return msg, nil
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-c.notify:
+		case <-c.done:
+			c.mu.Lock()
+			empty := len(c.pushes) == 0
+			c.mu.Unlock()
+			if empty {
+				return Message{}, c.Err()
+			}
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		}
+	}
+}
+
+// Done is closed when connection is closed
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns error which closed the connection,
+// *websocket.CloseError when server closed it with close frame
+func (c *Client) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// SendRaw sends raw text frame
+func (c *Client) SendRaw(frame []byte) error {
+	c.wl.Lock()
+	defer c.wl.Unlock()
+	return c.conn.WriteMessage(websocket.TextMessage, frame)
+}
+
+// SendRawBinary sends raw binary frame, see ws.BinaryFrame
+func (c *Client) SendRawBinary(frame []byte) error {
+	c.wl.Lock()
+	defer c.wl.Unlock()
+	return c.conn.WriteMessage(websocket.BinaryMessage, frame)
+}
+
+// Close sends close frame and closes the connection
+func (c *Client) Close() error {
+	c.wl.Lock()
+	c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	c.wl.Unlock()
+	return c.conn.Close()
+}
+
+func (c *Client) write(id int64, topic string, payload interface{}) error {
+	c.wl.Lock()
+	defer c.wl.Unlock()
+	return c.conn.WriteJSON(struct {
+		ID    int64
+		Topic string
+		Data  interface{}
+	}{id, topic, payload})
+}
+
+func (c *Client) read() {
+	defer close(c.done)
+
+	for {
+		kind, frame, err := c.conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				c.err = closeErr
+			} else {
+				c.err = ErrClosed
+			}
+			return
+		}
+
+		if kind == websocket.BinaryMessage {
+			c.receiveBinary(frame)
+			continue
+		}
+
+		// responses to array frame may be combined into array
+		var items []json.RawMessage
+		if err := json.Unmarshal(frame, &items); err != nil {
+			items = []json.RawMessage{frame}
+		}
+		for _, item := range items {
+			var msg Message
+			if err := json.Unmarshal(item, &msg); err != nil {
+				continue
+			}
+			msg.Raw = item
+			c.receive(msg)
+		}
+	}
+}
+
+func (c *Client) receive(msg Message) {
+	c.mu.Lock()
+	resp, ok := c.pending[msg.ID]
+	if ok && msg.ID != 0 {
+		delete(c.pending, msg.ID)
+		c.mu.Unlock()
+		resp <- msg
+		return
+	}
+	if msg.ID == 0 && msg.Seq != 0 && !c.sequence(msg) {
+		c.mu.Unlock()
+		return
+	}
+	if c.buffer == 0 {
+		c.dropped++
+		c.mu.Unlock()
+		return
+	}
+	if len(c.pushes) >= c.buffer {
+		c.dropped++
+		c.pushes = c.pushes[1:]
+	}
+	c.pushes = append(c.pushes, msg)
+	c.mu.Unlock()
+
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
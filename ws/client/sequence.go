@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+
+	"github.com/IAmRadek/go-kit/ws"
+)
+
+// Gap describes numbered pushes of stream which were not received,
+// pushes numbered after After and before Before are missing
+type Gap struct {
+	Stream string
+	After  int64
+	Before int64
+}
+
+// OnGap registers fn called in separate goroutine when numbered push arrives after gap in its stream,
+// missed pushes can be fetched with Resync
+func (c *Client) OnGap(fn func(g Gap)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onGap = fn
+}
+
+// LastSeq returns number of the last push of stream received
+func (c *Client) LastSeq(stream string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.seqs[stream]
+}
+
+// sequence tracks number of push and reports whether it should be delivered,
+// repeated pushes are dropped, must be called with c.mu held
+func (c *Client) sequence(msg Message) bool {
+	last := c.seqs[msg.Stream]
+	switch {
+	case msg.Seq == 1:
+		// numbering started again, e.g. room was emptied
+	case msg.Seq <= last:
+		return false
+	case last != 0 && msg.Seq != last+1 && c.onGap != nil:
+		go c.onGap(Gap{Stream: msg.Stream, After: last, Before: msg.Seq})
+	}
+	c.seqs[msg.Stream] = msg.Seq
+	return true
+}
+
+// Resync returns pushes of stream numbered after after, which are kept by server,
+// error with ws.CodeResyncUnavailable is returned if some of them are no longer kept
+func (c *Client) Resync(ctx context.Context, stream string, after int64) ([]Message, error) {
+	var msgs []Message
+	if err := c.Call(ctx, ws.ResyncTopic, ws.ResyncRequest{Stream: stream, After: after}, &msgs); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	for _, msg := range msgs {
+		if msg.Seq > c.seqs[stream] {
+			c.seqs[stream] = msg.Seq
+		}
+	}
+	c.mu.Unlock()
+	return msgs, nil
+}
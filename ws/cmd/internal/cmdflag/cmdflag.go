@@ -0,0 +1,24 @@
+// Package cmdflag contains flag types shared by the ws commands.
+package cmdflag
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Header is repeatable flag.Value of "Name: value" handshake headers
+type Header http.Header
+
+func (h Header) String() string {
+	return fmt.Sprint(http.Header(h))
+}
+
+func (h Header) Set(v string) error {
+	name, value, ok := strings.Cut(v, ":")
+	if !ok {
+		return fmt.Errorf("header %q is not in Name: value form", v)
+	}
+	http.Header(h).Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	return nil
+}
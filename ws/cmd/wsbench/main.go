@@ -0,0 +1,170 @@
+// Command wsbench load tests ws servers.
+//
+// It opens connections, sends requests framed as {ID, Topic, Data} at target rate
+// and reports latency percentiles, errors and dropped connections:
+//
+//	wsbench -url ws://localhost:8080/ws -conns 100 -rate 1000 -duration 30s \
+//		-topic 'echo=3:{"conn": {{.Conn}}, "seq": {{.Seq}}}' -topic 'stats=1'
+//
+// Payloads are text/template rendered with Conn (connection index), Seq (request sequence
+// of the connection) and Rand (random int), and must be valid JSON.
+//
+// With -rate, requests are scheduled at fixed intervals shared by all connections
+// and latency is measured from the scheduled time, so time spent waiting for a free
+// connection is included when the server falls behind. Achieved rate is reported
+// next to the target.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/client"
+	"github.com/IAmRadek/go-kit/ws/cmd/internal/cmdflag"
+)
+
+func main() {
+	var (
+		url      = flag.String("url", "ws://localhost:8080/", "websocket endpoint")
+		conns    = flag.Int("conns", 10, "number of connections")
+		rate     = flag.Float64("rate", 100, "requests per second of all connections, 0 sends as fast as responses arrive")
+		duration = flag.Duration("duration", 10*time.Second, "duration of the test")
+		timeout  = flag.Duration("timeout", 5*time.Second, "timeout of single request")
+		ramp     = flag.Duration("ramp", 0, "time over which connections are opened")
+		header   = cmdflag.Header{}
+		mix      mixFlag
+	)
+	flag.Var(header, "header", "header sent with handshake, e.g. 'Authorization: Bearer token', repeatable")
+	flag.Var(&mix, "topic", "topic with optional weight and payload template, e.g. 'echo=2:{\"n\": {{.Seq}}}', repeatable")
+	flag.Parse()
+
+	if len(mix) == 0 {
+		log.Fatal("wsbench: at least one -topic is required")
+	}
+	// ticker interval must be at least 1ns
+	if !(*rate >= 0 && *rate <= float64(time.Second)) {
+		log.Fatalf("wsbench: -rate must be between 0 and %d", time.Second)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration+*ramp)
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	stats := newStats()
+	start := time.Now()
+	var sched *schedule
+	if *rate > 0 {
+		sched = &schedule{start: start, interval: time.Duration(float64(time.Second) / *rate)}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < *conns; i++ {
+		if *ramp > 0 && i > 0 {
+			select {
+			case <-time.After(*ramp / time.Duration(*conns)):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		c, err := client.Dial(ctx, *url, http.Header(header))
+		if err != nil {
+			stats.connFailed(err)
+			continue
+		}
+		stats.connected()
+		// pushes are not read
+		c.SetPushBuffer(0)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer c.Close()
+			run(ctx, c, i, mix, sched, *timeout, stats)
+		}(i)
+	}
+	wg.Wait()
+
+	stats.report(os.Stdout, time.Since(start), *rate)
+}
+
+// schedule hands out send times spaced at target rate to all connections
+type schedule struct {
+	start    time.Time
+	interval time.Duration
+	next     int64
+}
+
+// take returns time at which next request should be sent
+func (s *schedule) take() time.Time {
+	n := atomic.AddInt64(&s.next, 1) - 1
+	return s.start.Add(time.Duration(n) * s.interval)
+}
+
+// run sends requests until ctx is done or connection is dropped
+func run(ctx context.Context, c *client.Client, conn int, mix mixFlag, sched *schedule, timeout time.Duration, stats *stats) {
+	for seq := 1; ; seq++ {
+		// latency is measured from scheduled time, which may be in the past when requests fall behind
+		var scheduled time.Time
+		if sched != nil {
+			scheduled = sched.take()
+			wait := time.NewTimer(time.Until(scheduled))
+			select {
+			case <-wait.C:
+			case <-ctx.Done():
+				wait.Stop()
+				return
+			case <-c.Done():
+				wait.Stop()
+				stats.dropped(c.Err())
+				return
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		t := mix.pick()
+		payload, err := t.render(conn, seq)
+		if err != nil {
+			stats.failed(t.Topic, "template")
+			continue
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, timeout)
+		if scheduled.IsZero() {
+			scheduled = time.Now()
+		}
+		err = c.Call(callCtx, t.Topic, payload, nil)
+		latency := time.Since(scheduled)
+		cancel()
+
+		var wsErr *ws.Error
+		switch {
+		case err == nil:
+			stats.succeeded(t.Topic, latency)
+		case errors.As(err, &wsErr):
+			stats.failed(t.Topic, string(wsErr.Code))
+		case ctx.Err() != nil:
+			// test ended while waiting for response
+			return
+		case errors.Is(err, context.DeadlineExceeded):
+			stats.failed(t.Topic, "timeout")
+		default:
+			stats.dropped(c.Err())
+			return
+		}
+	}
+}
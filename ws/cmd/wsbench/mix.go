@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// topic is request sent with weighted probability
+type topic struct {
+	Topic   string
+	Weight  int
+	Payload *template.Template
+}
+
+type mixFlag []topic
+
+func (m *mixFlag) String() string {
+	var topics []string
+	for _, t := range *m {
+		topics = append(topics, fmt.Sprintf("%s=%d", t.Topic, t.Weight))
+	}
+	return strings.Join(topics, ",")
+}
+
+// Set parses topic[=weight][:payload]
+func (m *mixFlag) Set(v string) error {
+	spec, payload, _ := strings.Cut(v, ":")
+	name, weight, hasWeight := strings.Cut(spec, "=")
+
+	t := topic{Topic: name, Weight: 1}
+	if name == "" {
+		return fmt.Errorf("topic is empty in %q", v)
+	}
+	if hasWeight {
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 1 {
+			return fmt.Errorf("invalid weight %q of topic %s", weight, name)
+		}
+		t.Weight = w
+	}
+	if payload != "" {
+		tmpl, err := template.New(name).Parse(payload)
+		if err != nil {
+			return fmt.Errorf("invalid payload of topic %s: %w", name, err)
+		}
+		t.Payload = tmpl
+	}
+
+	*m = append(*m, t)
+	return nil
+}
+
+// pick returns topic chosen according to weights
+func (m mixFlag) pick() topic {
+	total := 0
+	for _, t := range m {
+		total += t.Weight
+	}
+	n := rand.Intn(total)
+	for _, t := range m {
+		if n < t.Weight {
+			return t
+		}
+		n -= t.Weight
+	}
+	return m[len(m)-1]
+}
+
+type payloadData struct {
+	Conn int
+	Seq  int
+	Rand int
+}
+
+// render returns payload of the request, nil if topic has no payload template
+func (t topic) render(conn, seq int) (json.RawMessage, error) {
+	if t.Payload == nil {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	if err := t.Payload.Execute(&buf, payloadData{conn, seq, rand.Int()}); err != nil {
+		return nil, err
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("payload of topic %s is not valid JSON: %s", t.Topic, buf.Bytes())
+	}
+	return buf.Bytes(), nil
+}
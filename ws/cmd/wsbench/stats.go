@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+type topicStats struct {
+	latencies []time.Duration
+	errors    map[string]int
+}
+
+// stats collects results of all connections
+type stats struct {
+	mu         sync.Mutex
+	conns      int
+	connErrors map[string]int
+	drops      map[string]int
+	topics     map[string]*topicStats
+}
+
+func newStats() *stats {
+	return &stats{
+		connErrors: make(map[string]int),
+		drops:      make(map[string]int),
+		topics:     make(map[string]*topicStats),
+	}
+}
+
+func (s *stats) topic(name string) *topicStats {
+	t, ok := s.topics[name]
+	if !ok {
+		t = &topicStats{errors: make(map[string]int)}
+		s.topics[name] = t
+	}
+	return t
+}
+
+func (s *stats) connected() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conns++
+}
+
+func (s *stats) connFailed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connErrors[err.Error()]++
+}
+
+func (s *stats) dropped(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drops[fmt.Sprint(err)]++
+}
+
+func (s *stats) succeeded(topic string, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.topic(topic)
+	t.latencies = append(t.latencies, latency)
+}
+
+func (s *stats) failed(topic, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.topic(topic).errors[reason]++
+}
+
+// report prints results, target is requested rate, zero if requests were not paced
+func (s *stats) report(w io.Writer, elapsed time.Duration, target float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintf(w, "duration     %s\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "connections  %d opened, %d failed, %d dropped\n", s.conns, total(s.connErrors), total(s.drops))
+	printCounts(w, "  connect error", s.connErrors)
+	printCounts(w, "  dropped", s.drops)
+
+	var all []time.Duration
+	var failures int
+	names := make([]string, 0, len(s.topics))
+	for name, t := range s.topics {
+		names = append(names, name)
+		all = append(all, t.latencies...)
+		failures += total(t.errors)
+	}
+	sort.Strings(names)
+
+	requests := len(all) + failures
+	fmt.Fprintf(w, "requests     %d, %.2f%% errors\n", requests, percent(failures, requests))
+	achieved := float64(requests) / elapsed.Seconds()
+	if target > 0 {
+		fmt.Fprintf(w, "rate         %.1f/s achieved, %.1f/s target\n", achieved, target)
+	} else {
+		fmt.Fprintf(w, "rate         %.1f/s achieved, unlimited target\n", achieved)
+	}
+	fmt.Fprintf(w, "latency      %s\n\n", percentiles(all))
+
+	for _, name := range names {
+		t := s.topics[name]
+		errs := total(t.errors)
+		fmt.Fprintf(w, "%s: %d ok, %d errors, latency %s\n", name, len(t.latencies), errs, percentiles(t.latencies))
+		printCounts(w, "  error", t.errors)
+	}
+}
+
+func total(counts map[string]int) int {
+	n := 0
+	for _, c := range counts {
+		n += c
+	}
+	return n
+}
+
+func percent(n, of int) float64 {
+	if of == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(of)
+}
+
+func printCounts(w io.Writer, label string, counts map[string]int) {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s %s: %d\n", label, k, counts[k])
+	}
+}
+
+func percentiles(latencies []time.Duration) string {
+	if len(latencies) == 0 {
+		return "-"
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	at := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))].Round(time.Microsecond)
+	}
+	return fmt.Sprintf("p50 %s, p90 %s, p99 %s, max %s", at(0.5), at(0.9), at(0.99), at(1))
+}
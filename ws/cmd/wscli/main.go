@@ -0,0 +1,210 @@
+// Command wscli is interactive client of the ws protocol.
+//
+// Every input line is "topic [payload]" sent as request with auto-incremented ID,
+// payload which is not valid JSON is sent as string. Responses and pushes are printed
+// as they arrive, heartbeats of the ping hook are hidden unless -pings is set:
+//
+//	wscli -url ws://localhost:8080/ws -token secret
+//	> chat.join "lobby"
+//
+// With -script, lines of the file are sent one by one, each waiting for its response,
+// and the command exits with status 1 on the first error response, timeout or closed connection.
+// Empty lines and lines starting with # are skipped.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/IAmRadek/go-kit/ws/client"
+	"github.com/IAmRadek/go-kit/ws/cmd/internal/cmdflag"
+	"github.com/IAmRadek/go-kit/ws/hooks/ping"
+)
+
+func main() {
+	var (
+		url     = flag.String("url", "ws://localhost:8080/", "websocket endpoint")
+		token   = flag.String("token", "", "bearer token sent in Authorization header")
+		script  = flag.String("script", "", "file with requests sent non-interactively")
+		timeout = flag.Duration("timeout", 5*time.Second, "timeout of connecting and of requests in script mode")
+		pings   = flag.Bool("pings", false, "show heartbeats of the ping hook")
+		header  = cmdflag.Header{}
+	)
+	flag.Var(header, "header", "header sent with handshake, e.g. 'Cookie: session=1', repeatable")
+	flag.Parse()
+
+	if *token != "" {
+		http.Header(header).Set("Authorization", "Bearer "+*token)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	c, err := client.Dial(ctx, *url, http.Header(header))
+	cancel()
+	if err != nil {
+		log.Fatalf("wscli: could not connect: %v", err)
+	}
+	defer c.Close()
+
+	p := printer{w: os.Stdout, pings: *pings}
+
+	if *script != "" {
+		f, err := os.Open(*script)
+		if err != nil {
+			log.Fatalf("wscli: %v", err)
+		}
+		defer f.Close()
+
+		if err := runScript(c, f, p, *timeout); err != nil {
+			log.Printf("wscli: %v", err)
+			c.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	go p.printMessages(c)
+	interactive(c, os.Stdin, p)
+}
+
+// interactive sends requests read from r until EOF or connection is closed
+func interactive(c *client.Client, r io.Reader, p printer) {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			topic, payload, ok := parseLine(line)
+			if !ok {
+				continue
+			}
+			id, err := c.Send(topic, payload)
+			if err != nil {
+				log.Printf("wscli: send failed: %v", err)
+				return
+			}
+			p.sent(id, topic, payload)
+		case <-c.Done():
+			log.Printf("wscli: connection closed: %v", c.Err())
+			return
+		}
+	}
+}
+
+// runScript sends requests of the script waiting for their responses,
+// pushes received meanwhile are printed
+func runScript(c *client.Client, r io.Reader, p printer, timeout time.Duration) error {
+	go p.printMessages(c)
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		topic, payload, ok := parseLine(scanner.Text())
+		if !ok {
+			continue
+		}
+
+		p.sent(0, topic, payload)
+		var resp json.RawMessage
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := c.Call(ctx, topic, payload, &resp)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("line %d: %s: %w", n, topic, err)
+		}
+		p.print("<", 0, topic, resp)
+	}
+	return scanner.Err()
+}
+
+// parseLine returns topic and payload of the line, false for empty lines and comments
+func parseLine(line string) (string, json.RawMessage, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil, false
+	}
+
+	topic, rest, _ := strings.Cut(line, " ")
+	rest = strings.TrimSpace(rest)
+	if rest == "" {
+		return topic, nil, true
+	}
+	if json.Valid([]byte(rest)) {
+		return topic, json.RawMessage(rest), true
+	}
+	payload, _ := json.Marshal(rest)
+	return topic, payload, true
+}
+
+type printer struct {
+	w     io.Writer
+	pings bool
+}
+
+// printMessages prints responses and pushes until connection is closed
+func (p printer) printMessages(c *client.Client) {
+	for {
+		msg, err := c.Next(context.Background())
+		if err != nil {
+			return
+		}
+		if msg.ID == 0 && msg.Topic == ping.Topic && !p.pings {
+			continue
+		}
+		if msg.Error != nil {
+			p.print("!", msg.ID, msg.Topic, mustMarshal(msg.Error))
+			continue
+		}
+		direction := "<"
+		if msg.ID == 0 {
+			direction = "*"
+		}
+		p.print(direction, msg.ID, msg.Topic, msg.Data)
+	}
+}
+
+func (p printer) sent(id int64, topic string, payload json.RawMessage) {
+	p.print(">", id, topic, payload)
+}
+
+// print writes message prefixed with direction: > sent, < response, * push, ! error
+func (p printer) print(direction string, id int64, topic string, data json.RawMessage) {
+	prefix := direction
+	if id != 0 {
+		prefix = fmt.Sprintf("%s #%d", direction, id)
+	}
+
+	var pretty bytes.Buffer
+	if len(data) == 0 || json.Indent(&pretty, data, "", "  ") != nil {
+		pretty.Reset()
+		pretty.Write(data)
+	}
+	if pretty.Len() == 0 {
+		fmt.Fprintf(p.w, "%s %s\n", prefix, topic)
+		return
+	}
+	fmt.Fprintf(p.w, "%s %s %s\n", prefix, topic, pretty.String())
+}
+
+func mustMarshal(v interface{}) json.RawMessage {
+	b, _ := json.Marshal(v)
+	return b
+}
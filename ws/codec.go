@@ -0,0 +1,130 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Protocol selects framing of requests and responses used by endpoint
+type Protocol int
+
+const (
+	// Native frames requests and responses as {ID, Topic, Data}
+	Native Protocol = iota
+	// JSONRPC frames requests and responses as JSON-RPC 2.0,
+	// method is the topic and params are the handler payload
+	JSONRPC
+)
+
+func (m *ws) codec(p Protocol) codec {
+	switch p {
+	case JSONRPC:
+		return rpcCodec{}
+	default:
+		return nativeCodec{combine: m.batchOpts.Combine}
+	}
+}
+
+// BatchOptions configures frames carrying array of requests
+type BatchOptions struct {
+	// Concurrency is number of requests of single frame dispatched concurrently,
+	// requests are dispatched one by one if it is lower than 2
+	Concurrency int
+	// Combine sends responses to array frame of Native protocol as single array frame,
+	// JSON-RPC batches are always answered with single frame
+	Combine bool
+}
+
+// WithBatch configures dispatching of frames carrying array of requests
+func WithBatch(opts BatchOptions) Option {
+	return func(m *ws) {
+		m.batchOpts = opts
+	}
+}
+
+// codec translates frames to requests and responses to frames
+type codec interface {
+	// decode returns requests from the frame, invalid ones have err set,
+	// batch reports whether responses should be sent together
+	decode(frame []byte) (reqs []*Request, batch bool)
+
+	// encode returns value marshalled as response or push
+	encode(resp response) interface{}
+
+	// fatal reports whether connection should be closed after invalid request
+	fatal(err error) bool
+}
+
+// nativeCodec decodes frame with single request or array of requests
+type nativeCodec struct {
+	combine bool
+}
+
+func (c nativeCodec) decode(frame []byte) ([]*Request, bool) {
+	var items []json.RawMessage
+	if len(bytes.TrimSpace(frame)) == 0 || bytes.TrimSpace(frame)[0] != '[' {
+		return []*Request{decodeNative(frame)}, false
+	}
+	if err := json.Unmarshal(frame, &items); err != nil || len(items) == 0 {
+		return []*Request{{err: ErrInvalidPacket}}, false
+	}
+
+	reqs := make([]*Request, 0, len(items))
+	for _, item := range items {
+		req := decodeNative(item)
+		if req.err != nil {
+			req.err = errInvalidItem
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, c.combine
+}
+
+// errInvalidItem is invalid request of array frame, which is answered without closing connection
+var errInvalidItem = fmt.Errorf("%w in array frame", ErrInvalidPacket)
+
+func decodeNative(frame []byte) *Request {
+	var req Request
+	if err := json.Unmarshal(frame, &req); err != nil || req.ID == 0 || req.Topic == "" {
+		req.err = ErrInvalidPacket
+	}
+	return &req
+}
+
+func (nativeCodec) encode(resp response) interface{} {
+	return resp
+}
+
+func (nativeCodec) fatal(err error) bool {
+	return err != errInvalidItem
+}
+
+// batch collects responses to requests decoded from single frame
+type batch struct {
+	mu     sync.Mutex
+	closed bool
+	resps  []response
+}
+
+// collect stores response and reports whether batch was still open
+func (b *batch) collect(resp response) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return false
+	}
+	b.resps = append(b.resps, resp)
+	return true
+}
+
+// close returns collected responses, later responses are sent separately
+func (b *batch) close() []response {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	return b.resps
+}
@@ -0,0 +1,112 @@
+package ws
+
+import (
+	"bufio"
+	"compress/flate"
+	"errors"
+	"expvar"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// CompressionOptions configures permessage-deflate compression of outgoing frames
+type CompressionOptions struct {
+	// Level is flate compression level from -2 (flate.HuffmanOnly) to 9 (flate.BestCompression),
+	// zero means default level
+	Level int
+	// MinSize is payload size in bytes below which frames are sent uncompressed
+	MinSize int
+	// SkipTopics are topics which are never compressed, e.g. carrying already compressed data
+	SkipTopics []string
+}
+
+// WithCompression negotiates permessage-deflate with clients supporting it
+func WithCompression(opts CompressionOptions) Option {
+	return func(m *ws) {
+		if opts.Level < flate.HuffmanOnly || opts.Level > flate.BestCompression {
+			panic("ws: compression level must be between -2 and 9")
+		}
+
+		upgrader := *m.Upgrader
+		upgrader.EnableCompression = true
+		m.Upgrader = &upgrader
+
+		skip := make(map[string]struct{}, len(opts.SkipTopics))
+		for _, topic := range opts.SkipTopics {
+			skip[topic] = struct{}{}
+		}
+		m.compression = &compression{opts: opts, skip: skip}
+	}
+}
+
+var compressionStats = expvar.NewMap("websocket_compression")
+
+func init() {
+	compressionStats.Set("ratio", expvar.Func(func() interface{} {
+		payload := compressionStats.Get("payload_bytes")
+		wire := compressionStats.Get("wire_bytes")
+		if payload == nil || wire == nil || payload.(*expvar.Int).Value() == 0 {
+			return 1.0
+		}
+		return float64(wire.(*expvar.Int).Value()) / float64(payload.(*expvar.Int).Value())
+	}))
+}
+
+type compression struct {
+	opts CompressionOptions
+	skip map[string]struct{}
+}
+
+func (c *compression) shouldCompress(topic string, size int) bool {
+	if size < c.opts.MinSize {
+		return false
+	}
+	_, skip := c.skip[topic]
+	return !skip
+}
+
+// negotiated reports whether client offered permessage-deflate extension
+func negotiated(r *http.Request) bool {
+	for _, ext := range r.Header.Values("Sec-Websocket-Extensions") {
+		if strings.Contains(ext, "permessage-deflate") {
+			return true
+		}
+	}
+	return false
+}
+
+// countingWriter counts bytes written to the hijacked connection
+type countingWriter struct {
+	http.ResponseWriter
+	conn *countingConn
+}
+
+func (w *countingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("ws: response does not implement http.Hijacker")
+	}
+	conn, brw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.conn = &countingConn{Conn: conn}
+	return w.conn, brw, nil
+}
+
+type countingConn struct {
+	net.Conn
+	written int64
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	atomic.AddInt64(&c.written, int64(n))
+	return n, err
+}
+
+func (c *countingConn) Written() int64 {
+	return atomic.LoadInt64(&c.written)
+}
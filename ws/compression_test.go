@@ -0,0 +1,93 @@
+package ws_test
+
+import (
+	"expvar"
+	"strings"
+	"testing"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/posener/wstest"
+)
+
+func compressionStat(name string) int64 {
+	v := expvar.Get("websocket_compression").(*expvar.Map).Get(name)
+	if v == nil {
+		return 0
+	}
+	return v.(*expvar.Int).Value()
+}
+
+func TestCompression(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {}, ws.WithCompression(ws.CompressionOptions{
+		MinSize:    100,
+		SkipTopics: []string{"image"},
+	}))
+	large := strings.Repeat("compressible ", 100)
+	w.RegisterHandler("text", func(r *ws.Request, size string) {
+		if size == "large" {
+			r.Respond(large)
+		} else {
+			r.Respond("small")
+		}
+	})
+	w.RegisterHandler("image", func(r *ws.Request) {
+		r.Respond(large)
+	})
+
+	dialer := wstest.NewDialer(w)
+	dialer.EnableCompression = true
+	conn, _, err := dialer.Dial("ws://example.org/websocket", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		Request    packet
+		Compressed bool
+	}{
+		{packet{1, "text", "large"}, true},
+		{packet{2, "text", "small"}, false},
+		{packet{3, "image", nil}, false},
+	}
+
+	for _, test := range tests {
+		compressed := compressionStat("frames_compressed")
+		uncompressed := compressionStat("frames_uncompressed")
+
+		conn.WriteJSON(test.Request)
+		var resp packet
+		if err := conn.ReadJSON(&resp); err != nil {
+			t.Fatal(err)
+		}
+
+		gotCompressed := compressionStat("frames_compressed") - compressed
+		gotUncompressed := compressionStat("frames_uncompressed") - uncompressed
+		if test.Compressed && (gotCompressed != 1 || gotUncompressed != 0) ||
+			!test.Compressed && (gotCompressed != 0 || gotUncompressed != 1) {
+			t.Errorf("%s %v: compressed %d, uncompressed %d frames", test.Request.Topic, test.Request.Data, gotCompressed, gotUncompressed)
+		}
+	}
+
+	if payload, wire := compressionStat("payload_bytes"), compressionStat("wire_bytes"); wire >= payload {
+		t.Errorf("wire bytes %d should be smaller than payload bytes %d", wire, payload)
+	}
+}
+
+func TestCompressionLevel(t *testing.T) {
+	for _, level := range []int{-3, 10} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("compression level %d accepted", level)
+				}
+			}()
+			ws.NewWS(nil, func(connection *ws.Connection, err error) {},
+				ws.WithCompression(ws.CompressionOptions{Level: level}))
+		}()
+	}
+	for _, level := range []int{-2, 0, 9} {
+		ws.NewWS(nil, func(connection *ws.Connection, err error) {},
+			ws.WithCompression(ws.CompressionOptions{Level: level}))
+	}
+}
@@ -0,0 +1,145 @@
+package ws
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DedupOptions configures Dedup middleware
+type DedupOptions struct {
+	// Window is how long handled requests are remembered, 1 minute by default
+	Window time.Duration
+	// Clock measures the window, SystemClock by default
+	Clock Clock
+}
+
+// Dedup returns middleware which runs handler once per request ID of the connection,
+// or once per Request.IdempotencyKey of the principal (connection if it has no principal),
+// within the window. Repeated requests get responses and error of the first one
+// instead of running the handler again, requests arriving while the first is handled wait for it.
+//
+// Request IDs are chosen by client per connection, so only IdempotencyKey
+// deduplicates requests retried after reconnect.
+func Dedup(opts DedupOptions) Middleware {
+	if opts.Window == 0 {
+		opts.Window = time.Minute
+	}
+	if opts.Clock == nil {
+		opts.Clock = SystemClock
+	}
+	d := &dedup{opts: opts, entries: make(map[string]*dedupEntry)}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(r *Request) error {
+			key, ok := dedupKey(r)
+			if !ok {
+				return next(r)
+			}
+
+			e, first := d.begin(key)
+			if !first {
+				<-e.done
+				return e.replay(r)
+			}
+
+			// error returned by handler is sent by dispatch after the middleware returns,
+			// so it is not captured and replay returns it instead
+			tap := r.tap
+			r.tap = e.capture
+			err := next(r)
+			r.tap = tap
+			d.finish(key, e, err)
+			return err
+		}
+	}
+}
+
+// dedupKey returns key of the request, false for requests without ID and idempotency key
+func dedupKey(r *Request) (string, bool) {
+	if r.IdempotencyKey != "" {
+		scope := r.C.Principal()
+		if scope == "" {
+			scope = "conn:" + r.C.ID()
+		}
+		return scope + "\x00key:" + r.IdempotencyKey + "\x00" + r.Topic, true
+	}
+	if r.ID == 0 {
+		return "", false
+	}
+	return "conn:" + r.C.ID() + "\x00id:" + strconv.FormatInt(r.ID, 10) + "\x00" + r.Topic, true
+}
+
+type dedup struct {
+	opts DedupOptions
+
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+	expiry  []dedupExpiry
+}
+
+type dedupExpiry struct {
+	key string
+	at  time.Time
+}
+
+type dedupEntry struct {
+	done chan struct{}
+	err  error
+
+	mu    sync.Mutex
+	resps []response
+}
+
+// begin returns entry of the key and reports whether request is the first one
+func (d *dedup) begin(key string) (*dedupEntry, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.sweep()
+	if e, ok := d.entries[key]; ok {
+		return e, false
+	}
+	e := &dedupEntry{done: make(chan struct{})}
+	d.entries[key] = e
+	return e, true
+}
+
+func (d *dedup) finish(key string, e *dedupEntry, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	e.err = err
+	d.expiry = append(d.expiry, dedupExpiry{key, d.opts.Clock.Now().Add(d.opts.Window)})
+	close(e.done)
+}
+
+// sweep removes expired entries, must be called with d.mu held
+func (d *dedup) sweep() {
+	now := d.opts.Clock.Now()
+	for len(d.expiry) > 0 && !now.Before(d.expiry[0].at) {
+		delete(d.entries, d.expiry[0].key)
+		d.expiry = d.expiry[1:]
+	}
+}
+
+func (e *dedupEntry) capture(resp response) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.resps = append(e.resps, resp)
+}
+
+// replay sends responses of the first request as responses to r
+func (e *dedupEntry) replay(r *Request) error {
+	e.mu.Lock()
+	resps := append([]response(nil), e.resps...)
+	e.mu.Unlock()
+
+	for _, resp := range resps {
+		resp.ID = r.ID
+		if err := r.reply(resp); err != nil {
+			return err
+		}
+	}
+	return e.err
+}
@@ -0,0 +1,81 @@
+package ws_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/wstest"
+)
+
+func TestDedup(t *testing.T) {
+	clock := wstest.NewClock(time.Unix(0, 0))
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	w.Use(ws.Dedup(ws.DedupOptions{Window: time.Minute, Clock: clock}))
+
+	charges := 0
+	w.RegisterHandler("charge", func(r *ws.Request, amount int) {
+		charges++
+		r.Respond(charges)
+	})
+
+	c := wstest.Connect(t, w)
+	expect := func(want int) {
+		t.Helper()
+		var got int
+		if msg := c.Next(); msg.ID != 5 || msg.Decode(&got) != nil || got != want {
+			t.Errorf("got %d %s; want 5 %d", msg.ID, msg.Data, want)
+		}
+	}
+
+	c.Raw([]byte(`{"ID": 5, "Topic": "charge", "Data": 10}`))
+	expect(1)
+	c.Raw([]byte(`{"ID": 5, "Topic": "charge", "Data": 10}`))
+	expect(1)
+
+	clock.Advance(time.Minute)
+	c.Raw([]byte(`{"ID": 5, "Topic": "charge", "Data": 10}`))
+	expect(2)
+
+	// failed request is replayed with single error
+	fails := 0
+	w.RegisterHandler("fail", func(r *ws.Request) error {
+		fails++
+		return ws.ErrUnauthorized
+	})
+	for i := 0; i < 2; i++ {
+		c.Raw([]byte(`{"ID": 6, "Topic": "fail"}`))
+		if msg := c.Next(); msg.ID != 6 || msg.Error == nil || msg.Error.Code != "unauthorized" {
+			t.Errorf("got %d %+v; want 6 unauthorized", msg.ID, msg.Error)
+		}
+	}
+	c.ExpectNoPush(50 * time.Millisecond)
+	if fails != 1 {
+		t.Errorf("failing handler ran %d times; want 1", fails)
+	}
+
+	// idempotency key is shared by requests of the principal
+	bridge := ws.NewWS(nil, func(connection *ws.Connection, err error) {},
+		ws.WithPrincipal(func(r *http.Request) string { return r.Header.Get("User") }))
+	bridge.Use(ws.Dedup(ws.DedupOptions{}))
+	bridge.RegisterHandler("charge", func(r *ws.Request, amount int) {
+		charges++
+		r.Respond(charges)
+	})
+
+	for _, user := range []string{"alice", "alice", "bob"} {
+		req := httptest.NewRequest(http.MethodPost, "/topic/charge", strings.NewReader(`10`))
+		req.Header.Set("User", user)
+		req.Header.Set("Idempotency-Key", "order-1")
+		rec := httptest.NewRecorder()
+		bridge.HTTPBridge().ServeHTTP(rec, req)
+
+		want := map[string]string{"alice": "3", "bob": "4"}[user]
+		if got := strings.TrimSpace(rec.Body.String()); got != want {
+			t.Errorf("%s: got %s; want %s", user, got, want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package ws
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorCode is well-known code sent to clients in error responses
+type ErrorCode string
+
+const (
+	CodeUnknownTopic   ErrorCode = "unknown_topic"
+	CodeInvalidPayload ErrorCode = "invalid_payload"
+	CodeUnauthorized   ErrorCode = "unauthorized"
+	CodeRateLimited    ErrorCode = "rate_limited"
+	CodeInternal       ErrorCode = "internal"
+)
+
+// Error is sent to client in response to request which failed,
+// handlers can return it or wrap it to control what client sees
+type Error struct {
+	Code    ErrorCode
+	Message string
+	Details interface{} `json:",omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ws: %s: %s", e.Code, e.Message)
+}
+
+var ErrUnauthorized = &Error{Code: CodeUnauthorized, Message: "unauthorized"}
+var ErrRateLimited = &Error{Code: CodeRateLimited, Message: "rate limited"}
+
+// AsError maps err to Error sent to client,
+// errors which are not Error are reported as internal without exposing their message
+func AsError(err error) *Error {
+	var e *Error
+	switch {
+	case errors.As(err, &e):
+		return e
+	case errors.Is(err, ErrUnknownHandler):
+		return &Error{Code: CodeUnknownTopic, Message: "unknown topic"}
+	case errors.Is(err, ErrInvalidPacket):
+		return &Error{Code: CodeInvalidPayload, Message: "invalid packet"}
+	default:
+		return &Error{Code: CodeInternal, Message: "internal error"}
+	}
+}
+
+// SendError sends error response to the request with id
+func (conn *Connection) SendError(id int64, topic string, err error) error {
+	return conn.send(response{ID: id, Topic: topic, Error: AsError(err), err: err})
+}
+
+// Fail sends error response to client and returns error if failed
+func (r *Request) Fail(err error) error {
+	return r.reply(response{ID: r.ID, Topic: r.Topic, Error: AsError(err), err: err})
+}
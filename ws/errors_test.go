@@ -0,0 +1,70 @@
+package ws_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/wstest"
+)
+
+func TestErrorResponses(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	w.RegisterHandler("secret", func(r *ws.Request) error {
+		return fmt.Errorf("checking token: %w", ws.ErrUnauthorized)
+	})
+	w.RegisterHandler("quota", func(r *ws.Request) error {
+		return &ws.Error{Code: ws.CodeRateLimited, Message: "slow down", Details: map[string]int{"retryAfter": 5}}
+	})
+	w.RegisterHandler("broken", func(r *ws.Request) error {
+		return errors.New("database password is hunter2")
+	})
+	w.RegisterHandler("number", func(r *ws.Request, n int) {
+		r.Respond(n)
+	})
+	// results other than single error are ignored
+	w.RegisterHandler("legacy", func(r *ws.Request) (int, error) {
+		r.Respond("ok")
+		return 0, errors.New("ignored")
+	})
+
+	tests := []struct {
+		Topic   string
+		Payload interface{}
+		Code    ws.ErrorCode
+		Message string
+	}{
+		{"secret", nil, ws.CodeUnauthorized, "unauthorized"},
+		{"quota", nil, ws.CodeRateLimited, "slow down"},
+		{"broken", nil, ws.CodeInternal, "internal error"},
+		{"number", "NaN", ws.CodeInvalidPayload, ""},
+		{"missing", nil, ws.CodeUnknownTopic, "unknown topic"},
+	}
+
+	c := wstest.Connect(t, w)
+	for _, test := range tests {
+		err := c.TryCall(test.Topic, test.Payload, nil)
+
+		var wsErr *ws.Error
+		if !errors.As(err, &wsErr) {
+			t.Errorf("%s: got %v; want *ws.Error", test.Topic, err)
+			continue
+		}
+		if wsErr.Code != test.Code || test.Message != "" && wsErr.Message != test.Message {
+			t.Errorf("%s: got %s %q; want %s %q", test.Topic, wsErr.Code, wsErr.Message, test.Code, test.Message)
+		}
+	}
+
+	// connection survives failed requests
+	var n int
+	c.Call("number", 7, &n)
+	if n != 7 {
+		t.Errorf("number = %d; want 7", n)
+	}
+
+	var legacy string
+	if err := c.TryCall("legacy", nil, &legacy); err != nil || legacy != "ok" {
+		t.Errorf("legacy = %q %v; want ok", legacy, err)
+	}
+}
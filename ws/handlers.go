@@ -0,0 +1,38 @@
+package ws
+
+import (
+	"reflect"
+	"sort"
+)
+
+// HandlerInfo describes handler registered for topic, e.g. for generating clients
+type HandlerInfo struct {
+	Topic string
+	// In is type of the payload, nil if handler takes no payload
+	In reflect.Type
+	// Out is type of the response data, nil if unknown,
+	// it is known for handlers registered with Topic.Handle
+	Out reflect.Type
+}
+
+func handlerInput(handlerFn interface{}) reflect.Type {
+	fn := reflect.TypeOf(handlerFn)
+	if fn.NumIn() < 2 {
+		return nil
+	}
+	return fn.In(1)
+}
+
+// Handlers returns handlers registered for topics sorted by topic, without fallback
+func (m *ws) Handlers() []HandlerInfo {
+	rs := m.routes.load()
+
+	infos := make([]HandlerInfo, 0, len(rs.info))
+	for _, info := range rs.info {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Topic < infos[j].Topic
+	})
+	return infos
+}
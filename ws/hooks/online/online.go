@@ -4,6 +4,7 @@ import (
 	"expvar"
 
 	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/presence"
 )
 
 var online = expvar.NewInt("websocket_connections")
@@ -18,6 +19,19 @@ func PostHook(conn *ws.Connection) {
 	online.Add(-1)
 }
 
+// Track counts connections reported by presence tracker instead of using hooks,
+// only connections with principal are counted
+func Track(t *presence.Tracker) (cancel func()) {
+	return t.Subscribe(func(e presence.Event) {
+		switch e.Type {
+		case presence.Connect:
+			online.Add(1)
+		case presence.Disconnect:
+			online.Add(-1)
+		}
+	})
+}
+
 // Get returns online number in safe way
 func Get() int64 {
 	return online.Value()
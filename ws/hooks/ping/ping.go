@@ -1,8 +1,4 @@
 // Package ping provides hook for pinging websocket every 5 seconds
-//
-// TODO:
-//
-//	Add configuration
 package ping
 
 import (
@@ -11,18 +7,61 @@ import (
 	"github.com/IAmRadek/go-kit/ws"
 )
 
-// Hook is
+// Topic of heartbeat messages sent to clients
+const Topic = "pong"
+
+// Option configures hook returned by New
+type Option func(p *pinger)
+
+// WithInterval sets interval between heartbeats, 5 seconds by default
+func WithInterval(d time.Duration) Option {
+	return func(p *pinger) {
+		p.interval = d
+	}
+}
+
+// WithClock sets clock used for ticking, ws.SystemClock by default
+func WithClock(c ws.Clock) Option {
+	return func(p *pinger) {
+		p.clock = c
+	}
+}
+
+type pinger struct {
+	interval time.Duration
+	clock    ws.Clock
+}
+
+// New returns pre hook sending heartbeat to connection until it is closed
+func New(opts ...Option) ws.Hook {
+	p := &pinger{
+		interval: 5 * time.Second,
+		clock:    ws.SystemClock,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p.hook
+}
+
+var defaultHook = New()
+
+// Hook sends heartbeat every 5 seconds
 func Hook(c *ws.Connection) {
+	defaultHook(c)
+}
+
+func (p *pinger) hook(c *ws.Connection) {
 	ctx := c.Context()
+	ticker := p.clock.NewTicker(p.interval)
 	go func() {
-		ticker := time.NewTicker(5 * time.Second)
 		defer ticker.Stop()
 		for {
 			select {
 			case <-ctx.Done():
 				return
-			case <-ticker.C:
-				c.Send(0, "pong", time.Now().UnixNano())
+			case <-ticker.C():
+				c.Send(0, Topic, p.clock.Now().UnixNano())
 			}
 		}
 	}()
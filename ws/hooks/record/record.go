@@ -0,0 +1,206 @@
+// Package record provides hook capturing frames of ws connections to JSON Lines.
+//
+// Captured sessions can be replayed with wstest.Replay.
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IAmRadek/go-kit/ws"
+)
+
+// Redacted replaces values of redacted fields
+const Redacted = "[REDACTED]"
+
+// Entry is single captured frame
+type Entry struct {
+	Time      time.Time       `json:"time"`
+	Conn      string          `json:"conn"`
+	Direction ws.Direction    `json:"direction"`
+	ID        json.RawMessage `json:"id,omitempty"`
+	Topic     string          `json:"topic,omitempty"`
+	Size      int             `json:"size"`
+	// Binary reports whether frame is binary frame, stored as base64 string
+	Binary bool            `json:"binary,omitempty"`
+	Frame  json.RawMessage `json:"frame"`
+}
+
+// Data returns frame as sent over the connection
+func (e Entry) Data() ([]byte, error) {
+	if !e.Binary {
+		return e.Frame, nil
+	}
+	var data []byte
+	err := json.Unmarshal(e.Frame, &data)
+	return data, err
+}
+
+// Options configures Recorder
+type Options struct {
+	// Select chooses recorded connections, all connections are recorded if nil
+	Select func(conn *ws.Connection) bool
+	// Redact are names of object fields, at any depth of the frame, whose values are replaced with Redacted
+	Redact []string
+	// Clock timestamps entries, time of the frame is used if nil
+	Clock ws.Clock
+}
+
+// Recorder writes entries of selected connections
+type Recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+
+	opts   Options
+	redact map[string]struct{}
+}
+
+// New returns Recorder writing entries to w
+func New(w io.Writer, opts Options) *Recorder {
+	redact := make(map[string]struct{}, len(opts.Redact))
+	for _, field := range opts.Redact {
+		redact[field] = struct{}{}
+	}
+	return &Recorder{enc: json.NewEncoder(w), opts: opts, redact: redact}
+}
+
+// Hook is pre hook starting recording of the connection
+func (r *Recorder) Hook(conn *ws.Connection) {
+	if r.opts.Select != nil && !r.opts.Select(conn) {
+		return
+	}
+
+	id := conn.ID()
+	conn.Observe(func(f ws.Frame) {
+		r.record(id, f)
+	})
+}
+
+func (r *Recorder) record(conn string, f ws.Frame) {
+	e := NewEntry(conn, f)
+	if r.opts.Clock != nil {
+		e.Time = r.opts.Clock.Now()
+	}
+	if len(r.redact) > 0 && !e.Binary {
+		if frame, err := decode(e.Frame); err == nil {
+			e.Frame, _ = json.Marshal(r.redactValue(frame))
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.enc.Encode(e)
+}
+
+// NewEntry returns entry describing the frame of connection
+func NewEntry(conn string, f ws.Frame) Entry {
+	e := Entry{
+		Time:      f.Time,
+		Conn:      conn,
+		Direction: f.Direction,
+		Size:      len(f.Data),
+	}
+
+	if f.Binary {
+		e.Binary = true
+		e.Frame, _ = json.Marshal(f.Data)
+		if id, _, err := ws.ParseBinaryFrame(f.Data); err == nil {
+			e.ID = json.RawMessage(strconv.FormatInt(id, 10))
+		}
+		return e
+	}
+
+	frame, err := decode(f.Data)
+	if err != nil {
+		// not JSON, store frame as string
+		e.Frame, _ = json.Marshal(string(f.Data))
+		return e
+	}
+
+	e.ID, e.Topic = describe(frame)
+	e.Frame = append(json.RawMessage(nil), f.Data...)
+	return e
+}
+
+func decode(data []byte) (interface{}, error) {
+	var frame interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err := dec.Decode(&frame)
+	return frame, err
+}
+
+// describe returns id and topic of native or JSON-RPC frame
+func describe(frame interface{}) (json.RawMessage, string) {
+	obj, ok := frame.(map[string]interface{})
+	if !ok {
+		return nil, ""
+	}
+
+	var id json.RawMessage
+	for _, field := range []string{"ID", "id"} {
+		if v, ok := obj[field]; ok {
+			id, _ = json.Marshal(v)
+			break
+		}
+	}
+	for _, field := range []string{"Topic", "method"} {
+		if topic, ok := obj[field].(string); ok {
+			return id, topic
+		}
+	}
+	return id, ""
+}
+
+func (r *Recorder) redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if _, ok := r.redact[key]; ok {
+				v[key] = Redacted
+				continue
+			}
+			v[key] = r.redactValue(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = r.redactValue(value)
+		}
+	}
+	return v
+}
+
+// Read returns entries written by Recorder
+func Read(rd io.Reader) ([]Entry, error) {
+	var entries []Entry
+
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// Session returns entries of the connection, entries of the first connection if conn is empty
+func Session(entries []Entry, conn string) []Entry {
+	var session []Entry
+	for _, e := range entries {
+		if conn == "" {
+			conn = e.Conn
+		}
+		if e.Conn == conn {
+			session = append(session, e)
+		}
+	}
+	return session
+}
@@ -0,0 +1,144 @@
+package record_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/hooks/record"
+	"github.com/IAmRadek/go-kit/ws/wstest"
+)
+
+type credentials struct {
+	Login    string
+	Password string
+}
+
+func newWS(rec *record.Recorder) ws.WS {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	if rec != nil {
+		w.AddPreHook(rec.Hook)
+	}
+	w.RegisterHandler("login", func(r *ws.Request, c credentials) {
+		r.C.Send(0, "welcome", c.Login)
+		r.Respond("OK")
+	})
+	return w
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	var capture bytes.Buffer
+	rec := record.New(&capture, record.Options{Redact: []string{"Password"}})
+
+	c := wstest.Connect(t, newWS(rec))
+	var resp string
+	c.Call("login", credentials{"alice", "secret"}, &resp)
+	var welcome string
+	c.ExpectPush("welcome", &welcome)
+	c.Close()
+
+	if strings.Contains(capture.String(), "secret") {
+		t.Errorf("capture contains redacted password:\n%s", capture.String())
+	}
+
+	entries, err := record.Read(&capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := record.Session(entries, "")
+
+	var got []string
+	for _, e := range session {
+		got = append(got, string(e.Direction)+" "+e.Topic+" "+string(e.ID))
+	}
+	want := []string{"in login 1", "out welcome 0", "out login 1"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("session = %v; want %v", got, want)
+	}
+
+	transcript := wstest.Replay(t, newWS(nil), session)
+	if len(transcript) != len(session) {
+		t.Fatalf("transcript has %d entries; want %d", len(transcript), len(session))
+	}
+	for i := range session {
+		if transcript[i].Direction != session[i].Direction || transcript[i].Topic != session[i].Topic ||
+			string(transcript[i].ID) != string(session[i].ID) {
+			t.Errorf("transcript[%d] = %+v; want %+v", i, transcript[i], session[i])
+		}
+	}
+}
+
+func newBinaryWS(rec *record.Recorder) ws.WS {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	if rec != nil {
+		w.AddPreHook(rec.Hook)
+	}
+	w.RegisterHandler("upload", func(r *ws.Request) {
+		r.Upload(ws.UploadOptions{}, func(data io.Reader) (interface{}, error) {
+			b, err := io.ReadAll(data)
+			return len(b), err
+		})
+	})
+	w.RegisterHandler("download", func(r *ws.Request) error {
+		return r.Download(bytes.NewReader(binaryData), ws.DownloadOptions{})
+	})
+	return w
+}
+
+// binaryData is not valid UTF-8
+var binaryData = []byte{0xff, 0xfe, 0x00, 0x80}
+
+func TestRecordBinary(t *testing.T) {
+	var capture bytes.Buffer
+	rec := record.New(&capture, record.Options{})
+
+	c := wstest.Connect(t, newBinaryWS(rec))
+	ctx, cancel := context.WithTimeout(context.Background(), wstest.Timeout)
+	defer cancel()
+
+	var size int
+	if err := c.Upload(ctx, "upload", nil, bytes.NewReader(binaryData), 0, &size); err != nil || size != len(binaryData) {
+		t.Fatalf("upload = %d %v; want %d", size, err, len(binaryData))
+	}
+	var downloaded bytes.Buffer
+	if err := c.Download(ctx, "download", nil, &downloaded); err != nil || !bytes.Equal(downloaded.Bytes(), binaryData) {
+		t.Fatalf("download = %x %v; want %x", downloaded.Bytes(), err, binaryData)
+	}
+	c.Close()
+
+	entries, err := record.Read(&capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := record.Session(entries, "")
+
+	var got []string
+	for _, e := range session {
+		got = append(got, fmt.Sprintf("%s %t %s", e.Direction, e.Binary, e.ID))
+		if data, err := e.Data(); err != nil || e.Binary && len(data) > 8 && !bytes.Equal(data[8:], binaryData) {
+			t.Errorf("data of %+v = %x %v; want %x", e, data, err, binaryData)
+		}
+	}
+	want := []string{
+		"in false 1", "in true 1", "in true 1", "out false 1",
+		"in false 2", "out true 2", "out true 2",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("session = %v; want %v", got, want)
+	}
+
+	transcript := wstest.Replay(t, newBinaryWS(nil), session)
+	if len(transcript) != len(session) {
+		t.Fatalf("transcript has %d entries; want %d", len(transcript), len(session))
+	}
+	for i := range session {
+		if transcript[i].Direction != session[i].Direction || transcript[i].Binary != session[i].Binary ||
+			string(transcript[i].Frame) != string(session[i].Frame) {
+			t.Errorf("transcript[%d] = %+v; want %+v", i, transcript[i], session[i])
+		}
+	}
+}
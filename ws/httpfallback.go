@@ -0,0 +1,440 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IAmRadek/go-kit/random"
+	"github.com/gorilla/websocket"
+)
+
+// HTTPFallbackOptions configures transports served by WS.HTTPFallback
+type HTTPFallbackOptions struct {
+	// PollTimeout is how long poll request waits for messages, 25 seconds by default
+	PollTimeout time.Duration
+	// SessionTimeout closes long-polling session not polled for that long, 1 minute by default
+	SessionTimeout time.Duration
+	// MaxQueue is number of messages queued for long-polling session
+	// after which session is closed, 1024 by default
+	MaxQueue int
+	// MaxFrameSize limits size of request body sent by client, 1MB by default
+	MaxFrameSize int64
+}
+
+// WithHTTPFallback configures transports served by WS.HTTPFallback
+func WithHTTPFallback(opts HTTPFallbackOptions) Option {
+	return func(m *ws) {
+		m.fallbackOpts = opts
+	}
+}
+
+var ErrSessionClosed = errors.New("ws: session closed")
+
+func (o HTTPFallbackOptions) withDefaults() HTTPFallbackOptions {
+	if o.PollTimeout == 0 {
+		o.PollTimeout = 25 * time.Second
+	}
+	if o.SessionTimeout == 0 {
+		o.SessionTimeout = time.Minute
+	}
+	if o.MaxQueue == 0 {
+		o.MaxQueue = 1024
+	}
+	if o.MaxFrameSize == 0 {
+		o.MaxFrameSize = 1 << 20
+	}
+	return o
+}
+
+// HTTPFallback returns handler serving the protocol over plain HTTP
+// for clients which cannot upgrade to websocket:
+//
+//	GET  .../sse                  opens Server-Sent Events session, first "session" event carries session token
+//	POST .../poll                 opens long-polling session, responds with {"session": token}
+//	GET  .../poll?session=token&ack=cursor
+//	                              waits for messages, responds with JSON array of frames
+//	                              and X-Poll-Cursor header, passed as ack of the next poll
+//	                              to acknowledge them, ack of the first poll is 0,
+//	                              frames not acknowledged are sent again
+//	POST .../send?session=token   sends request frame(s) in the body
+//	POST .../close?session=token  closes the session
+//
+// Sessions use the same handlers, hooks and Connection as websocket endpoints.
+// Connection.Request of long-polling session is the finished POST request opening it,
+// so its Context is already canceled, use Connection.Context instead.
+func (m *ws) HTTPFallback(p Protocol) http.Handler {
+	return &httpFallback{m: m, codec: m.codec(p), opts: m.fallbackOpts.withDefaults()}
+}
+
+type httpFallback struct {
+	m     *ws
+	codec codec
+	opts  HTTPFallbackOptions
+}
+
+func (f *httpFallback) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/sse"):
+		f.serveSSE(w, r)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/poll"):
+		f.openPoll(w, r)
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/poll"):
+		f.poll(w, r)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/send"):
+		f.send(w, r)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/close"):
+		f.close(w, r)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// session is connection served over HTTP requests
+type session struct {
+	token  string
+	conn   *Connection
+	inbox  chan []byte
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (s *session) read() ([]byte, error) {
+	select {
+	case frame := <-s.inbox:
+		return frame, nil
+	case <-s.ctx.Done():
+		if t, ok := s.conn.transport.(*pollTransport); ok {
+			if closed := t.closeInfo(); closed != nil {
+				return nil, &websocket.CloseError{Code: closed.Code, Text: closed.Reason}
+			}
+		}
+		return nil, ErrSessionClosed
+	}
+}
+
+func (f *httpFallback) newSession(ctx context.Context, r *http.Request, t transport) *session {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &session{
+		token:  random.Hex(32),
+		inbox:  make(chan []byte, 16),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	s.conn = f.m.newConnection(ctx, r, t, f.codec)
+
+	f.m.mu.Lock()
+	f.m.sessions[s.token] = s
+	f.m.mu.Unlock()
+
+	return s
+}
+
+func (f *httpFallback) run(s *session) {
+	f.m.run(s.conn, s.read)
+	s.cancel()
+
+	remove := func() {
+		f.m.mu.Lock()
+		delete(f.m.sessions, s.token)
+		f.m.mu.Unlock()
+	}
+	if _, ok := s.conn.transport.(*pollTransport); ok {
+		// keep closed session, so client can poll the close reason
+		time.AfterFunc(f.opts.SessionTimeout, remove)
+		return
+	}
+	remove()
+}
+
+func (f *httpFallback) session(w http.ResponseWriter, r *http.Request) (*session, bool) {
+	f.m.mu.RLock()
+	s, ok := f.m.sessions[r.URL.Query().Get("session")]
+	f.m.mu.RUnlock()
+
+	if !ok {
+		http.Error(w, "Unknown session", http.StatusNotFound)
+	}
+	return s, ok
+}
+
+func (f *httpFallback) send(w http.ResponseWriter, r *http.Request) {
+	s, ok := f.session(w, r)
+	if !ok {
+		return
+	}
+
+	frame, err := io.ReadAll(http.MaxBytesReader(w, r.Body, f.opts.MaxFrameSize))
+	if err != nil {
+		http.Error(w, "Request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	select {
+	case s.inbox <- frame:
+		w.WriteHeader(http.StatusAccepted)
+	case <-s.ctx.Done():
+		http.Error(w, "Session closed", http.StatusGone)
+	case <-r.Context().Done():
+	}
+}
+
+func (f *httpFallback) close(w http.ResponseWriter, r *http.Request) {
+	s, ok := f.session(w, r)
+	if !ok {
+		return
+	}
+
+	s.conn.Close(websocket.CloseNormalClosure, "")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type sessionInfo struct {
+	Session string `json:"session"`
+}
+
+// sseTransport streams frames as Server-Sent Events
+type sseTransport struct {
+	l       sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+	cancel  context.CancelFunc
+	closed  bool
+}
+
+func (f *httpFallback) serveSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	release, status := f.m.admit(r)
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	defer release()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	t := &sseTransport{w: w, flusher: flusher}
+	s := f.newSession(r.Context(), r, t)
+	t.cancel = s.cancel
+
+	info, _ := json.Marshal(sessionInfo{s.token})
+	t.event("session", info)
+
+	f.run(s)
+
+	// response must not be written after handler returns
+	t.l.Lock()
+	t.closed = true
+	t.l.Unlock()
+}
+
+func (t *sseTransport) event(name string, data []byte) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	if t.closed {
+		return ErrSessionClosed
+	}
+	if _, err := fmt.Fprintf(t.w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return err
+	}
+	t.flusher.Flush()
+	return nil
+}
+
+func (t *sseTransport) write(topic string, frame []byte) error {
+	return t.event("message", frame)
+}
+
+func (t *sseTransport) writeBinary(frame []byte) error {
+	return ErrBinaryUnsupported
+}
+
+func (t *sseTransport) close(code int, reason string) error {
+	data, _ := json.Marshal(closeInfo{code, reason})
+	err := t.event("close", data)
+
+	t.l.Lock()
+	t.closed = true
+	t.l.Unlock()
+
+	t.cancel()
+	return err
+}
+
+type closeInfo struct {
+	Code   int    `json:"code"`
+	Reason string `json:"reason"`
+}
+
+// pollTransport queues frames until client acknowledges them
+type pollTransport struct {
+	l     sync.Mutex
+	queue []json.RawMessage
+	// first is cursor of the first queued frame
+	first  int64
+	notify chan struct{}
+	closed *closeInfo
+	max    int
+	cancel context.CancelFunc
+	expiry *time.Timer
+}
+
+func (f *httpFallback) openPoll(w http.ResponseWriter, r *http.Request) {
+	release, status := f.m.admit(r)
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	t := &pollTransport{notify: make(chan struct{}, 1), max: f.opts.MaxQueue}
+	s := f.newSession(context.Background(), r, t)
+	t.cancel = s.cancel
+	t.expiry = time.AfterFunc(f.opts.SessionTimeout, func() {
+		s.conn.Close(websocket.CloseGoingAway, "session expired")
+	})
+
+	go func() {
+		defer release()
+		f.run(s)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessionInfo{s.token})
+}
+
+func (t *pollTransport) write(topic string, frame []byte) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	if t.closed != nil {
+		return ErrSessionClosed
+	}
+	if len(t.queue) >= t.max {
+		t.closed = &closeInfo{websocket.ClosePolicyViolation, "queue overflow"}
+		t.cancel()
+		t.wake()
+		return ErrSessionClosed
+	}
+
+	t.queue = append(t.queue, frame)
+	t.wake()
+	return nil
+}
+
+func (t *pollTransport) writeBinary(frame []byte) error {
+	return ErrBinaryUnsupported
+}
+
+func (t *pollTransport) close(code int, reason string) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	if t.closed == nil {
+		t.closed = &closeInfo{code, reason}
+	}
+	t.cancel()
+	t.wake()
+	return nil
+}
+
+// closeInfo returns code and reason with which session was closed, nil if it is open
+func (t *pollTransport) closeInfo() *closeInfo {
+	t.l.Lock()
+	defer t.l.Unlock()
+	return t.closed
+}
+
+// wake must be called with t.l held
+func (t *pollTransport) wake() {
+	select {
+	case t.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take removes frames before ack and returns the remaining ones with cursor after them,
+// close info if session is closed and nothing is queued
+func (t *pollTransport) take(ack int64) ([]json.RawMessage, int64, *closeInfo) {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	if ack > t.first+int64(len(t.queue)) {
+		ack = t.first + int64(len(t.queue))
+	}
+	if ack > t.first {
+		t.queue = append([]json.RawMessage(nil), t.queue[ack-t.first:]...)
+		t.first = ack
+	}
+
+	if len(t.queue) == 0 && t.closed != nil {
+		return nil, t.first, t.closed
+	}
+	return t.queue, t.first + int64(len(t.queue)), nil
+}
+
+func (f *httpFallback) poll(w http.ResponseWriter, r *http.Request) {
+	s, ok := f.session(w, r)
+	if !ok {
+		return
+	}
+	t, ok := s.conn.transport.(*pollTransport)
+	if !ok {
+		http.Error(w, "Not a polling session", http.StatusBadRequest)
+		return
+	}
+
+	// ack is required, so frames of response lost in transit are sent again
+	ack, err := strconv.ParseInt(r.URL.Query().Get("ack"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ack", http.StatusBadRequest)
+		return
+	}
+
+	t.expiry.Stop()
+	defer t.expiry.Reset(f.opts.SessionTimeout)
+
+	timeout := time.NewTimer(f.opts.PollTimeout)
+	defer timeout.Stop()
+
+	for {
+		frames, cursor, closed := t.take(ack)
+		if closed != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusGone)
+			json.NewEncoder(w).Encode(closed)
+			return
+		}
+		w.Header().Set("X-Poll-Cursor", strconv.FormatInt(cursor, 10))
+		if len(frames) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(frames)
+			return
+		}
+
+		select {
+		case <-t.notify:
+		case <-timeout.C:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("[]\n"))
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package ws_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/gorilla/websocket"
+)
+
+func newFallbackServer(t *testing.T) *httptest.Server {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {}, ws.WithHTTPFallback(ws.HTTPFallbackOptions{
+		PollTimeout: 100 * time.Millisecond,
+	}))
+	w.RegisterHandler("echo", func(r *ws.Request, text string) {
+		r.Respond(text)
+	})
+
+	server := httptest.NewServer(http.StripPrefix("/rt", w.HTTPFallback(ws.Native)))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestLongPolling(t *testing.T) {
+	server := newFallbackServer(t)
+
+	resp, err := http.Post(server.URL+"/rt/poll", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info struct{ Session string }
+	json.NewDecoder(resp.Body).Decode(&info)
+	resp.Body.Close()
+
+	var cursor string
+	pollAck := func(ack string) (int, []packet) {
+		resp, err := http.Get(server.URL + "/rt/poll?session=" + info.Session + ack)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		var frames []packet
+		json.NewDecoder(resp.Body).Decode(&frames)
+		cursor = resp.Header.Get("X-Poll-Cursor")
+		return resp.StatusCode, frames
+	}
+	if status, _ := pollAck(""); status != http.StatusBadRequest {
+		t.Errorf("poll without ack = %d; want 400", status)
+	}
+	if status, frames := pollAck("&ack=0"); status != http.StatusOK || len(frames) != 0 {
+		t.Errorf("empty poll = %d %v; want 200 []", status, frames)
+	}
+
+	resp, err = http.Post(server.URL+"/rt/send?session="+info.Session, "application/json", strings.NewReader(`{"ID":1,"Topic":"echo","Data":"hi"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("send = %d; want 202", resp.StatusCode)
+	}
+
+	status, frames := pollAck("&ack=0")
+	if status != http.StatusOK || len(frames) != 1 || frames[0].Id != 1 || frames[0].Data != "hi" {
+		t.Errorf("poll = %d %+v; want echo response", status, frames)
+	}
+
+	// frames not acknowledged are sent again
+	status, frames = pollAck("&ack=0")
+	if status != http.StatusOK || len(frames) != 1 || frames[0].Id != 1 || cursor != "1" {
+		t.Errorf("poll without acknowledging = %d %+v cursor %s; want echo response again and cursor 1", status, frames, cursor)
+	}
+	if status, frames := pollAck("&ack=" + cursor); status != http.StatusOK || len(frames) != 0 {
+		t.Errorf("poll after ack = %d %+v; want 200 []", status, frames)
+	}
+
+	resp, err = http.Post(server.URL+"/rt/close?session="+info.Session, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if status, _ := pollAck("&ack=" + cursor); status != http.StatusGone {
+		t.Errorf("poll after close = %d; want 410", status)
+	}
+}
+
+func TestLongPollingExpiry(t *testing.T) {
+	errs := make(chan error, 1)
+	closed := make(chan int, 1)
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) { errs <- err }, ws.WithHTTPFallback(ws.HTTPFallbackOptions{
+		SessionTimeout: 50 * time.Millisecond,
+	}))
+	w.AddLifecycle(ws.LifecycleFuncs{
+		Close: func(conn *ws.Connection, code int, reason string, err error) {
+			closed <- code
+		},
+	})
+	server := httptest.NewServer(w.HTTPFallback(ws.Native))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/poll", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	select {
+	case code := <-closed:
+		if code != websocket.CloseGoingAway {
+			t.Errorf("closed with %d; want %d", code, websocket.CloseGoingAway)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("session not expired")
+	}
+	select {
+	case err := <-errs:
+		t.Errorf("expiry reported as error: %v", err)
+	default:
+	}
+}
+
+func TestServerSentEvents(t *testing.T) {
+	server := newFallbackServer(t)
+
+	resp, err := http.Get(server.URL + "/rt/sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	events := bufio.NewReader(resp.Body)
+	next := func() (string, string) {
+		var name, data string
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			case line == "":
+				return name, data
+			}
+		}
+	}
+
+	name, data := next()
+	var info struct{ Session string }
+	json.Unmarshal([]byte(data), &info)
+	if name != "session" || info.Session == "" {
+		t.Fatalf("first event = %s %s; want session", name, data)
+	}
+
+	send, err := http.Post(server.URL+"/rt/send?session="+info.Session, "application/json", strings.NewReader(`{"ID":7,"Topic":"echo","Data":"hello"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	send.Body.Close()
+
+	name, data = next()
+	var frame packet
+	json.Unmarshal([]byte(data), &frame)
+	if name != "message" || frame.Id != 7 || frame.Data != "hello" {
+		t.Errorf("event = %s %s; want echo response", name, data)
+	}
+}
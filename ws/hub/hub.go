@@ -0,0 +1,368 @@
+// Package hub provides ws.Broker working over TCP or Unix socket
+// without any external services.
+//
+// One process runs Server, every WS instance connects to it with Dial
+// and Server relays each published message to all connected clients.
+// Clients dial Server again when connection is lost, e.g. when Server restarts
+// or disconnects client which does not keep up, messages relayed in the meantime are lost.
+package hub
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/IAmRadek/go-kit/ws"
+)
+
+// ErrClosed is returned by Client.Err when connection to Server is closed
+var ErrClosed = errors.New("hub: connection closed")
+
+const (
+	// queueSize is number of messages queued for client, client which does not keep up is disconnected
+	queueSize = 1024
+	// writeTimeout limits time of writing single message to client
+	writeTimeout = 10 * time.Second
+	// handshakeTimeout limits time of connecting to Server and receiving its ack
+	handshakeTimeout = 10 * time.Second
+
+	// minBackoff and maxBackoff limit delay between attempts to dial lost Server
+	minBackoff = 50 * time.Millisecond
+	maxBackoff = 5 * time.Second
+)
+
+// ack is sent to client once it is registered, so messages published after Dial reach it
+const ack = "ok"
+
+// Server relays messages between connected clients
+type Server struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	clients map[net.Conn]*peer
+	closed  bool
+}
+
+// peer is client connected to Server, messages are written to it by its own goroutine
+type peer struct {
+	conn  net.Conn
+	queue chan []byte
+	done  chan struct{}
+}
+
+// Listen announces on the local network address and starts relaying messages
+func Listen(network, address string) (*Server, error) {
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		ln:      ln,
+		clients: make(map[net.Conn]*peer),
+	}
+	go s.serve()
+
+	return s, nil
+}
+
+// Addr returns address server listens on
+func (s *Server) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+// Close stops listening and disconnects all clients
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.clients {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	return s.ln.Close()
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		p := &peer{conn: conn, queue: make(chan []byte, queueSize), done: make(chan struct{})}
+		s.clients[conn] = p
+		// ack is queued before any relayed message
+		p.queue <- []byte(ack + "\n")
+		s.mu.Unlock()
+
+		go p.write()
+		go s.handle(p)
+	}
+}
+
+func (s *Server) handle(p *peer) {
+	conn := p.conn
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, conn)
+		s.mu.Unlock()
+		close(p.done)
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		// line is queued for clients, so it must not share scanner buffer
+		line := append(append([]byte(nil), scanner.Bytes()...), '\n')
+		s.relay(line)
+	}
+}
+
+// relay queues line for every client, so stalled client does not delay others
+func (s *Server) relay(line []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.clients {
+		select {
+		case p.queue <- line:
+		case <-p.done:
+		default:
+			// client does not keep up, it is disconnected and dials again
+			p.conn.Close()
+		}
+	}
+}
+
+func (p *peer) write() {
+	for {
+		select {
+		case line := <-p.queue:
+			p.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if _, err := p.conn.Write(line); err != nil {
+				p.conn.Close()
+				return
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// Client is ws.Broker connected to Server,
+// lost connection is dialed again with backoff and subscribers are kept
+type Client struct {
+	network string
+	address string
+
+	wl   sync.Mutex
+	conn net.Conn
+	enc  *json.Encoder
+	err  error
+
+	mu   sync.RWMutex
+	next int
+	subs map[int]func(ws.Message)
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+var _ ws.Broker = (*Client)(nil)
+
+// Dial connects to Server listening on the address,
+// it returns once Server relays messages to the client
+func Dial(network, address string) (*Client, error) {
+	conn, scanner, err := handshake(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		network: network,
+		address: address,
+		subs:    make(map[int]func(ws.Message)),
+		done:    make(chan struct{}),
+	}
+	c.connected(conn)
+	go c.run(conn, scanner)
+
+	return c, nil
+}
+
+// handshake dials Server and waits for ack sent once the client is registered
+func handshake(network, address string) (net.Conn, *bufio.Scanner, error) {
+	conn, err := net.DialTimeout(network, address, handshakeTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(nil, 16<<20)
+	conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	if !scanner.Scan() || scanner.Text() != ack {
+		conn.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, errors.New("hub: invalid handshake")
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	return conn, scanner, nil
+}
+
+// Close disconnects from Server and stops dialing it again
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+
+	c.wl.Lock()
+	defer c.wl.Unlock()
+	c.err = ErrClosed
+	if c.conn == nil {
+		return nil
+	}
+	conn := c.conn
+	c.conn = nil
+	return conn.Close()
+}
+
+// Done is closed when Client is closed
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns error which closed the connection while Client dials Server again,
+// messages relayed in the meantime are not received, nil while it is connected
+func (c *Client) Err() error {
+	c.wl.Lock()
+	defer c.wl.Unlock()
+	return c.err
+}
+
+// Publish sends message to Server which relays it to every client,
+// Err is returned while Client is not connected
+func (c *Client) Publish(msg ws.Message) error {
+	c.wl.Lock()
+	defer c.wl.Unlock()
+	if c.conn == nil {
+		return c.err
+	}
+	if err := c.enc.Encode(msg); err != nil {
+		// reader notices closed connection and dials again
+		c.conn.Close()
+		return err
+	}
+	return nil
+}
+
+// Subscribe registers fn to be called for every message relayed by Server
+func (c *Client) Subscribe(fn func(msg ws.Message)) (func(), error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := c.next
+	c.next++
+	c.subs[id] = fn
+
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.subs, id)
+	}, nil
+}
+
+// connected publishes conn, it returns false when Client was closed in the meantime
+func (c *Client) connected(conn net.Conn) bool {
+	c.wl.Lock()
+	defer c.wl.Unlock()
+
+	select {
+	case <-c.done:
+		conn.Close()
+		return false
+	default:
+	}
+	c.conn = conn
+	c.enc = json.NewEncoder(conn)
+	c.err = nil
+	return true
+}
+
+func (c *Client) disconnected(conn net.Conn, err error) {
+	conn.Close()
+
+	c.wl.Lock()
+	defer c.wl.Unlock()
+	if c.conn != conn {
+		// closed by Close
+		return
+	}
+	c.conn = nil
+	c.err = err
+}
+
+// run reads messages of the connection and dials Server again when it is lost
+func (c *Client) run(conn net.Conn, scanner *bufio.Scanner) {
+	for {
+		c.disconnected(conn, c.read(scanner))
+
+		backoff := minBackoff
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-time.After(backoff):
+			}
+
+			var err error
+			conn, scanner, err = handshake(c.network, c.address)
+			if err == nil {
+				break
+			}
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+		if !c.connected(conn) {
+			return
+		}
+	}
+}
+
+// read calls subscribers with messages until connection fails
+func (c *Client) read(scanner *bufio.Scanner) error {
+	for scanner.Scan() {
+		var msg ws.Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+
+		c.mu.RLock()
+		subs := make([]func(ws.Message), 0, len(c.subs))
+		for _, fn := range c.subs {
+			subs = append(subs, fn)
+		}
+		c.mu.RUnlock()
+
+		for _, fn := range subs {
+			fn(msg)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return ErrClosed
+}
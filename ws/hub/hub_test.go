@@ -0,0 +1,177 @@
+package hub_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/hub"
+)
+
+func TestRelay(t *testing.T) {
+	server, err := hub.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	publisher, err := hub.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+
+	subscriber, err := hub.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscriber.Close()
+
+	received := make(chan ws.Message, 1)
+	subscriber.Subscribe(func(msg ws.Message) {
+		received <- msg
+	})
+
+	sent := ws.Message{Origin: "a", Room: "lobby", Topic: "hello", Data: json.RawMessage(`"world"`)}
+	if err := publisher.Publish(sent); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-received:
+		if msg.Origin != sent.Origin || msg.Room != sent.Room || msg.Topic != sent.Topic || string(msg.Data) != string(sent.Data) {
+			t.Errorf("received %+v; want %+v", msg, sent)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message not relayed")
+	}
+}
+
+func TestStalledClient(t *testing.T) {
+	server, err := hub.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	// stalled client never reads relayed messages after ack of its registration
+	stalled, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stalled.Close()
+	if _, err := bufio.NewReader(stalled).ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := hub.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	received := make(chan struct{}, 1)
+	client.Subscribe(func(msg ws.Message) {
+		received <- struct{}{}
+	})
+
+	// messages exceed socket buffers of the stalled client
+	data, _ := json.Marshal(strings.Repeat("x", 64<<10))
+	for i := 0; i < 256; i++ {
+		if err := client.Publish(ws.Message{Topic: "fill", Data: data}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message %d not relayed", i)
+		}
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	server, err := hub.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := server.Addr().String()
+
+	client, err := hub.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	received := make(chan ws.Message, 16)
+	client.Subscribe(func(msg ws.Message) {
+		received <- msg
+	})
+
+	if err := client.Err(); err != nil {
+		t.Errorf("got %v while connected", err)
+	}
+	server.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for client.Err() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("disconnect not noticed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// restarted server is dialed again and subscribers are kept
+	server, err = hub.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		if client.Publish(ws.Message{Topic: "hello"}) == nil {
+			select {
+			case <-received:
+				if err := client.Err(); err != nil {
+					t.Errorf("got %v after reconnect", err)
+				}
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("client did not reconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClose(t *testing.T) {
+	server, err := hub.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	client, err := hub.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+
+	select {
+	case <-client.Done():
+	default:
+		t.Fatal("Done not closed")
+	}
+	if err := client.Err(); err != hub.ErrClosed {
+		t.Errorf("Err() = %v; want %v", err, hub.ErrClosed)
+	}
+	if err := client.Publish(ws.Message{Topic: "hello"}); err != hub.ErrClosed {
+		t.Errorf("Publish() = %v; want %v", err, hub.ErrClosed)
+	}
+}
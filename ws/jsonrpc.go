@@ -0,0 +1,165 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	RPCParseError     = -32700
+	RPCInvalidRequest = -32600
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+	RPCInternalError  = -32603
+	RPCServerError    = -32000
+	RPCUnauthorized   = -32001
+	RPCRateLimited    = -32002
+)
+
+var errRPCParse = fmt.Errorf("%w: parse error", ErrInvalidPacket)
+
+var rpcNull = json.RawMessage("null")
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcResult struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result"`
+}
+
+type rpcErrorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   rpcError        `json:"error"`
+}
+
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type rpcNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type rpcCodec struct{}
+
+func (rpcCodec) decode(frame []byte) ([]*Request, bool) {
+	frame = bytes.TrimSpace(frame)
+	if len(frame) == 0 || frame[0] != '[' {
+		return []*Request{decodeRPC(frame)}, false
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(frame, &items); err != nil {
+		return []*Request{{rawID: rpcNull, err: errRPCParse}}, false
+	}
+	if len(items) == 0 {
+		return []*Request{{rawID: rpcNull, err: ErrInvalidPacket}}, false
+	}
+
+	reqs := make([]*Request, 0, len(items))
+	for _, item := range items {
+		reqs = append(reqs, decodeRPC(item))
+	}
+	return reqs, true
+}
+
+func decodeRPC(frame []byte) *Request {
+	if !json.Valid(frame) {
+		return &Request{rawID: rpcNull, err: errRPCParse}
+	}
+
+	var msg rpcRequest
+	if err := json.Unmarshal(frame, &msg); err != nil {
+		return &Request{rawID: rpcNull, err: ErrInvalidPacket}
+	}
+
+	req := &Request{
+		Topic: msg.Method,
+		Data:  msg.Params,
+		rawID: msg.ID,
+	}
+	if msg.JSONRPC != "2.0" || msg.Method == "" {
+		if req.rawID == nil {
+			req.rawID = rpcNull
+		}
+		req.err = ErrInvalidPacket
+		return req
+	}
+
+	if req.rawID == nil {
+		req.notification = true
+	} else if id, err := strconv.ParseInt(string(req.rawID), 10, 64); err == nil {
+		req.ID = id
+	}
+	return req
+}
+
+func (rpcCodec) encode(resp response) interface{} {
+	id := resp.rawID
+	if id == nil && resp.ID != 0 {
+		id = json.RawMessage(strconv.FormatInt(resp.ID, 10))
+	}
+
+	if resp.Error != nil {
+		if id == nil {
+			id = rpcNull
+		}
+		return rpcErrorResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error: rpcError{
+				Code:    rpcCode(resp.err, resp.Error),
+				Message: resp.Error.Message,
+				Data:    resp.Error.Details,
+			},
+		}
+	}
+
+	if id == nil {
+		return rpcNotification{JSONRPC: "2.0", Method: resp.Topic, Params: resp.Data}
+	}
+	return rpcResult{JSONRPC: "2.0", ID: id, Result: resp.Data}
+}
+
+func (rpcCodec) fatal(err error) bool {
+	return false
+}
+
+func rpcCode(err error, e *Error) int {
+	switch {
+	case errors.Is(err, errRPCParse):
+		return RPCParseError
+	case errors.Is(err, ErrInvalidPacket):
+		return RPCInvalidRequest
+	}
+
+	switch e.Code {
+	case CodeUnknownTopic:
+		return RPCMethodNotFound
+	case CodeInvalidPayload:
+		return RPCInvalidParams
+	case CodeInternal:
+		return RPCInternalError
+	case CodeUnauthorized:
+		return RPCUnauthorized
+	case CodeRateLimited:
+		return RPCRateLimited
+	default:
+		return RPCServerError
+	}
+}
@@ -0,0 +1,89 @@
+package ws_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/google/go-cmp/cmp"
+	"github.com/posener/wstest"
+)
+
+func TestJSONRPC(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	w.RegisterHandler("add", func(r *ws.Request, args []int) {
+		sum := 0
+		for _, a := range args {
+			sum += a
+		}
+		r.Respond(sum)
+	})
+	w.RegisterHandler("notify", func(r *ws.Request, text string) {
+		r.C.Send(0, "notified", text)
+	})
+
+	conn, _, err := wstest.NewDialer(w.Handler(ws.JSONRPC)).Dial("ws://example.org/websocket", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		Name     string
+		Request  string
+		Response string
+	}{
+		{"numeric id", `{"jsonrpc":"2.0","method":"add","params":[1,2],"id":1}`,
+			`{"jsonrpc":"2.0","id":1,"result":3}`},
+		{"string id", `{"jsonrpc":"2.0","method":"add","params":[2,2],"id":"abc"}`,
+			`{"jsonrpc":"2.0","id":"abc","result":4}`},
+		{"notification push", `{"jsonrpc":"2.0","method":"notify","params":"hello"}`,
+			`{"jsonrpc":"2.0","method":"notified","params":"hello"}`},
+		{"method not found", `{"jsonrpc":"2.0","method":"missing","id":2}`,
+			`{"jsonrpc":"2.0","id":2,"error":{"code":-32601,"message":"unknown topic"}}`},
+		{"parse error", `{"jsonrpc":"2.0","method"`,
+			`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"invalid packet"}}`},
+		{"invalid request", `{"jsonrpc":"1.0","method":"add","id":3}`,
+			`{"jsonrpc":"2.0","id":3,"error":{"code":-32600,"message":"invalid packet"}}`},
+		{"empty batch", `[]`,
+			`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"invalid packet"}}`},
+		{"batch", `[
+			{"jsonrpc":"2.0","method":"add","params":[1],"id":4},
+			{"jsonrpc":"2.0","method":"add","params":[1]},
+			1,
+			{"jsonrpc":"2.0","method":"missing","id":5}
+		]`, `[
+			{"jsonrpc":"2.0","id":4,"result":1},
+			{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"invalid packet"}},
+			{"jsonrpc":"2.0","id":5,"error":{"code":-32601,"message":"unknown topic"}}
+		]`},
+	}
+
+	for _, test := range tests {
+		if err := conn.WriteMessage(1, []byte(test.Request)); err != nil {
+			t.Fatal(err)
+		}
+		_, got, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var gotValue, wantValue interface{}
+		json.Unmarshal(got, &gotValue)
+		json.Unmarshal([]byte(test.Response), &wantValue)
+		if diff := cmp.Diff(wantValue, gotValue); diff != "" {
+			t.Errorf("%s: response mismatch (-want +got):\n%s", test.Name, diff)
+		}
+	}
+
+	// invalid params
+	conn.WriteMessage(1, []byte(`{"jsonrpc":"2.0","method":"add","params":"x","id":6}`))
+	var resp struct {
+		ID    int
+		Error struct{ Code int }
+	}
+	conn.ReadJSON(&resp)
+	if resp.ID != 6 || resp.Error.Code != ws.RPCInvalidParams {
+		t.Errorf("invalid params response = %+v", resp)
+	}
+}
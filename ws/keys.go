@@ -0,0 +1,144 @@
+package ws
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync/atomic"
+)
+
+// Key identifies typed value stored on Connection, it should be declared once as package variable:
+//
+//	var userKey = ws.NewKey[*User]("user")
+//
+//	userKey.Set(conn, user)
+//	user, ok := userKey.Get(conn)
+type Key[T any] struct {
+	id       uint64
+	name     string
+	onRemove func(conn *Connection, value T)
+}
+
+// keySeq orders keys by creation, so keys with the same name are listed in stable order
+var keySeq uint64
+
+// NewKey returns new key, name is used only for debugging
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{id: atomic.AddUint64(&keySeq, 1), name: name}
+}
+
+// OnRemove sets function called when value is deleted, replaced or connection is closed
+func (k *Key[T]) OnRemove(fn func(conn *Connection, value T)) *Key[T] {
+	k.onRemove = fn
+	return k
+}
+
+// Name returns name of the key
+func (k *Key[T]) Name() string {
+	return k.name
+}
+
+// Get returns value stored on the connection
+func (k *Key[T]) Get(conn *Connection) (value T, ok bool) {
+	conn.sl.Lock()
+	defer conn.sl.Unlock()
+
+	e, ok := conn.state[k]
+	if !ok {
+		return value, false
+	}
+	return e.value.(T), true
+}
+
+// Set stores value on the connection,
+// value set after connection is closed is not stored and OnRemove is called right away
+func (k *Key[T]) Set(conn *Connection, value T) {
+	conn.sl.Lock()
+	if conn.stateClosed {
+		conn.sl.Unlock()
+		k.remove(conn, value)
+		return
+	}
+	old, replaced := conn.state[k]
+	if conn.state == nil {
+		conn.state = make(map[interface{}]stateEntry)
+	}
+	conn.state[k] = stateEntry{id: k.id, name: k.name, value: value, remove: k.remove}
+	conn.sl.Unlock()
+
+	if replaced {
+		old.remove(conn, old.value)
+	}
+}
+
+// Delete removes value from the connection
+func (k *Key[T]) Delete(conn *Connection) {
+	conn.sl.Lock()
+	old, ok := conn.state[k]
+	delete(conn.state, k)
+	conn.sl.Unlock()
+
+	if ok {
+		old.remove(conn, old.value)
+	}
+}
+
+func (k *Key[T]) remove(conn *Connection, value interface{}) {
+	if k.onRemove != nil {
+		k.onRemove(conn, value.(T))
+	}
+}
+
+type stateEntry struct {
+	id     uint64
+	name   string
+	value  interface{}
+	remove func(conn *Connection, value interface{})
+}
+
+// DebugValues lists values stored on the connection using keys, by key names,
+// keys sharing name created later are listed as "name#2", "name#3" and so on
+func (conn *Connection) DebugValues() map[string]string {
+	conn.sl.Lock()
+	entries := make([]stateEntry, 0, len(conn.state))
+	for _, e := range conn.state {
+		entries = append(entries, e)
+	}
+	conn.sl.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].id < entries[j].id
+	})
+	values := make(map[string]string, len(entries))
+	seen := make(map[string]int, len(entries))
+	for _, e := range entries {
+		name := e.name
+		if seen[e.name]++; seen[e.name] > 1 {
+			name += "#" + strconv.Itoa(seen[e.name])
+		}
+		values[name] = fmt.Sprintf("%+v", e.value)
+	}
+	return values
+}
+
+// clearState removes all values, calling OnRemove functions in key name order
+func (conn *Connection) clearState() {
+	conn.cancelSubscriptions()
+	conn.cancelUploads()
+
+	conn.sl.Lock()
+	entries := make([]stateEntry, 0, len(conn.state))
+	for _, e := range conn.state {
+		entries = append(entries, e)
+	}
+	conn.state = nil
+	conn.stateClosed = true
+	conn.sl.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+	for _, e := range entries {
+		e.remove(conn, e.value)
+	}
+}
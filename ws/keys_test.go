@@ -0,0 +1,108 @@
+package ws_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/wstest"
+)
+
+type session struct {
+	User string
+}
+
+func TestKeys(t *testing.T) {
+	removed := make(chan string, 2)
+	sessionKey := ws.NewKey[*session]("session").OnRemove(func(conn *ws.Connection, s *session) {
+		removed <- s.User
+	})
+	counterKey := ws.NewKey[int]("counter")
+
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	w.AddPreHook(func(conn *ws.Connection) {
+		sessionKey.Set(conn, &session{User: "alice"})
+	})
+	w.RegisterHandler("whoami", func(r *ws.Request) {
+		s, _ := sessionKey.Get(r.C)
+		n, _ := counterKey.Get(r.C)
+		counterKey.Set(r.C, n+1)
+		r.Respond(s.User)
+	})
+	w.RegisterHandler("logout", func(r *ws.Request) {
+		sessionKey.Set(r.C, &session{User: "anonymous"})
+		r.Respond(r.C.DebugValues())
+	})
+
+	c := wstest.Connect(t, w)
+
+	var user string
+	c.Call("whoami", nil, &user)
+	if user != "alice" {
+		t.Errorf("whoami = %q; want alice", user)
+	}
+
+	var values map[string]string
+	c.Call("logout", nil, &values)
+	want := map[string]string{"session": "&{User:anonymous}", "counter": "1"}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("DebugValues() = %v; want %v", values, want)
+	}
+	if got := <-removed; got != "alice" {
+		t.Errorf("replaced value %q; want alice", got)
+	}
+
+	c.Close()
+	if got := <-removed; got != "anonymous" {
+		t.Errorf("removed value on close %q; want anonymous", got)
+	}
+}
+
+func TestKeysAfterClose(t *testing.T) {
+	removed := make(chan string, 1)
+	sessionKey := ws.NewKey[*session]("session").OnRemove(func(conn *ws.Connection, s *session) {
+		removed <- s.User
+	})
+
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	conns := make(chan *ws.Connection, 1)
+	w.RegisterHandler("login", func(r *ws.Request) {
+		conns <- r.C
+		r.Respond("ok")
+	})
+
+	c := wstest.Connect(t, w)
+	c.Call("login", nil, nil)
+	conn := <-conns
+	c.Close()
+	<-conn.Context().Done()
+
+	// value set late, e.g. by handler goroutine, is removed right away
+	sessionKey.Set(conn, &session{User: "late"})
+	if got := <-removed; got != "late" {
+		t.Errorf("removed value %q; want late", got)
+	}
+	if _, ok := sessionKey.Get(conn); ok {
+		t.Errorf("value of closed connection is kept")
+	}
+}
+
+func TestDebugValuesDuplicateNames(t *testing.T) {
+	first := ws.NewKey[string]("user")
+	second := ws.NewKey[string]("user")
+
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	w.RegisterHandler("values", func(r *ws.Request) {
+		second.Set(r.C, "bob")
+		first.Set(r.C, "alice")
+		r.Respond(r.C.DebugValues())
+	})
+
+	c := wstest.Connect(t, w)
+	var values map[string]string
+	c.Call("values", nil, &values)
+	want := map[string]string{"user": "alice", "user#2": "bob"}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("DebugValues() = %v; want %v", values, want)
+	}
+}
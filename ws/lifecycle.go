@@ -0,0 +1,129 @@
+package ws
+
+import (
+	"errors"
+
+	"github.com/gorilla/websocket"
+)
+
+// Lifecycle receives events of connections, see WS.AddLifecycle
+type Lifecycle interface {
+	// OnConnect is called before connection is served, returned error rejects the connection
+	// with code of *websocket.CloseError or 1008 (policy violation) and error message as reason
+	OnConnect(conn *Connection) error
+
+	// OnMessage is called with every frame received from the client
+	OnMessage(conn *Connection, frame []byte)
+
+	// OnError is called with every error of the connection reported to ErrorHandler
+	OnError(conn *Connection, err error)
+
+	// OnClose is called when connection accepted by OnConnect of the lifecycle ends,
+	// with code and reason sent by the client or passed to Connection.Close,
+	// err is the error which ended the connection, nil if it ended with close frame
+	OnClose(conn *Connection, code int, reason string, err error)
+}
+
+// LifecycleFuncs implements Lifecycle with optional functions
+type LifecycleFuncs struct {
+	Connect func(conn *Connection) error
+	Message func(conn *Connection, frame []byte)
+	Error   func(conn *Connection, err error)
+	Close   func(conn *Connection, code int, reason string, err error)
+}
+
+func (l LifecycleFuncs) OnConnect(conn *Connection) error {
+	if l.Connect == nil {
+		return nil
+	}
+	return l.Connect(conn)
+}
+
+func (l LifecycleFuncs) OnMessage(conn *Connection, frame []byte) {
+	if l.Message != nil {
+		l.Message(conn, frame)
+	}
+}
+
+func (l LifecycleFuncs) OnError(conn *Connection, err error) {
+	if l.Error != nil {
+		l.Error(conn, err)
+	}
+}
+
+func (l LifecycleFuncs) OnClose(conn *Connection, code int, reason string, err error) {
+	if l.Close != nil {
+		l.Close(conn, code, reason, err)
+	}
+}
+
+// AddLifecycle appends lifecycle receiving events of every connection
+func (m *ws) AddLifecycle(l Lifecycle) {
+	m.lifecycles = append(m.lifecycles, l)
+}
+
+// connect calls OnConnect of lifecycles, connection is closed if any rejects it
+func (m *ws) connect(conn *Connection) bool {
+	for i, l := range m.lifecycles {
+		err := l.OnConnect(conn)
+		if err == nil {
+			continue
+		}
+
+		code, reason := websocket.ClosePolicyViolation, err.Error()
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) {
+			code, reason = closeErr.Code, closeErr.Text
+		}
+		conn.Close(code, reason)
+
+		for _, accepted := range m.lifecycles[:i] {
+			accepted.OnClose(conn, code, reason, err)
+		}
+		return false
+	}
+	return true
+}
+
+// reportError passes error of the connection to ErrorHandler and lifecycles
+func (m *ws) reportError(conn *Connection, err error) {
+	m.ErrorHandler(conn, err)
+	for _, l := range m.lifecycles {
+		l.OnError(conn, err)
+	}
+}
+
+// Close terminates connection with the close code and reason,
+// e.g. websocket.ClosePolicyViolation, which are passed to Lifecycle.OnClose
+func (conn *Connection) Close(code int, reason string) error {
+	conn.cl.Lock()
+	if conn.closeErr == nil {
+		conn.closeErr = &websocket.CloseError{Code: code, Text: reason}
+	}
+	conn.cl.Unlock()
+
+	return conn.transport.close(code, reason)
+}
+
+// closedByServer reports whether Close was called
+func (conn *Connection) closedByServer() bool {
+	conn.cl.Lock()
+	defer conn.cl.Unlock()
+	return conn.closeErr != nil
+}
+
+// closeStatus returns code and reason with which connection ended after read failed with readErr,
+// error is nil if connection was closed with close frame by either side
+func (conn *Connection) closeStatus(readErr error) (int, string, error) {
+	conn.cl.Lock()
+	defer conn.cl.Unlock()
+
+	if conn.closeErr != nil {
+		return conn.closeErr.Code, conn.closeErr.Text, nil
+	}
+	var closeErr *websocket.CloseError
+	if errors.As(readErr, &closeErr) {
+		return closeErr.Code, closeErr.Text, nil
+	}
+	return websocket.CloseAbnormalClosure, "", readErr
+}
@@ -0,0 +1,73 @@
+package ws_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/wstest"
+	"github.com/gorilla/websocket"
+)
+
+type closed struct {
+	Code   int
+	Reason string
+	Err    error
+}
+
+func TestLifecycle(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	w.RegisterHandler("quit", func(r *ws.Request, reason string) {
+		r.C.Close(4000, reason)
+	})
+
+	messages := make(chan []byte, 10)
+	closes := make(chan closed, 10)
+	w.AddLifecycle(ws.LifecycleFuncs{
+		Connect: func(conn *ws.Connection) error {
+			if conn.Request.Header.Get("Banned") != "" {
+				return &websocket.CloseError{Code: 4001, Text: "banned"}
+			}
+			return nil
+		},
+		Message: func(conn *ws.Connection, frame []byte) {
+			messages <- frame
+		},
+		Close: func(conn *ws.Connection, code int, reason string, err error) {
+			closes <- closed{code, reason, err}
+		},
+	})
+
+	expectClosed := func(want closed) {
+		t.Helper()
+		select {
+		case got := <-closes:
+			if got != want {
+				t.Errorf("closed %+v; want %+v", got, want)
+			}
+		case <-time.After(wstest.Timeout):
+			t.Fatalf("OnClose not called")
+		}
+	}
+
+	banned := wstest.Connect(t, w, wstest.WithHeader(http.Header{"Banned": {"yes"}}))
+	banned.ExpectClose(4001)
+
+	c := wstest.Connect(t, w)
+	c.Send("quit", "bye")
+	c.ExpectClose(4000)
+	expectClosed(closed{4000, "bye", nil})
+	if len(messages) != 1 {
+		t.Errorf("got %d messages; want 1", len(messages))
+	}
+
+	c = wstest.Connect(t, w)
+	c.Close()
+	expectClosed(closed{websocket.CloseNormalClosure, "", nil})
+
+	c = wstest.Connect(t, w)
+	c.Raw([]byte(`{"Topic": "quit"}`))
+	c.ExpectClose(websocket.CloseInvalidFramePayloadData)
+	expectClosed(closed{websocket.CloseInvalidFramePayloadData, "invalid packet", nil})
+}
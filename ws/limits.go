@@ -0,0 +1,120 @@
+package ws
+
+import (
+	"expvar"
+	"net/http"
+	"sync"
+
+	"github.com/IAmRadek/go-kit/ip"
+)
+
+// Limits caps number of concurrent connections, zero means no limit
+type Limits struct {
+	// Total limits connections served by WS, exceeding requests are rejected with 503
+	Total int
+	// PerIP limits connections of single client IP, exceeding requests are rejected with 429
+	PerIP int
+	// TrustedProxy reports whether peer with the ip is proxy whose X-Forwarded-For is trusted,
+	// the header is ignored if nil, so clients can not spoof their IP
+	TrustedProxy func(ip string) bool
+	// PerPrincipal limits connections of single principal, see WithPrincipal,
+	// exceeding requests are rejected with 429
+	PerPrincipal int
+}
+
+// WithLimits rejects connections exceeding limits before the upgrade,
+// limits apply to sessions of HTTPFallback too
+func WithLimits(limits Limits) Option {
+	return func(m *ws) {
+		m.admission = &admission{
+			limits:     limits,
+			ips:        make(map[string]int),
+			principals: make(map[string]int),
+		}
+	}
+}
+
+var limitStats = expvar.NewMap("websocket_limits")
+
+// admission counts connections admitted by limits
+type admission struct {
+	mu         sync.Mutex
+	limits     Limits
+	total      int
+	ips        map[string]int
+	principals map[string]int
+}
+
+// admit reserves connection slot for the request, release must be called when connection is closed.
+// Status is non zero if request is rejected.
+func (m *ws) admit(r *http.Request) (release func(), status int) {
+	if m.admission == nil {
+		return func() {}, 0
+	}
+
+	var principal string
+	if m.principal != nil {
+		principal = m.principal(r)
+	}
+	return m.admission.admit(m.clientIP(r), principal)
+}
+
+// clientIP returns ip of the client, X-Forwarded-For is used only if sent by TrustedProxy of limits
+func (m *ws) clientIP(r *http.Request) string {
+	var trusted func(ip string) bool
+	if m.admission != nil {
+		trusted = m.admission.limits.TrustedProxy
+	}
+	return ip.FromTrustedRequest(r, trusted)
+}
+
+func (a *admission) admit(addr, principal string) (release func(), status int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch {
+	case a.limits.Total > 0 && a.total >= a.limits.Total:
+		limitStats.Add("rejected_total", 1)
+		return nil, http.StatusServiceUnavailable
+	case a.limits.PerIP > 0 && a.ips[addr] >= a.limits.PerIP:
+		limitStats.Add("rejected_ip", 1)
+		return nil, http.StatusTooManyRequests
+	case a.limits.PerPrincipal > 0 && principal != "" && a.principals[principal] >= a.limits.PerPrincipal:
+		limitStats.Add("rejected_principal", 1)
+		return nil, http.StatusTooManyRequests
+	}
+
+	a.total++
+	limitStats.Add("connections", 1)
+	if a.ips[addr]++; a.ips[addr] == 1 {
+		limitStats.Add("ips", 1)
+	}
+	if principal != "" {
+		if a.principals[principal]++; a.principals[principal] == 1 {
+			limitStats.Add("principals", 1)
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { a.release(addr, principal) })
+	}, 0
+}
+
+func (a *admission) release(addr, principal string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.total--
+	limitStats.Add("connections", -1)
+	if a.ips[addr]--; a.ips[addr] == 0 {
+		delete(a.ips, addr)
+		limitStats.Add("ips", -1)
+	}
+	if principal != "" {
+		if a.principals[principal]--; a.principals[principal] == 0 {
+			delete(a.principals, principal)
+			limitStats.Add("principals", -1)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package ws_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/wstest"
+)
+
+func TestLimits(t *testing.T) {
+	principal := func(r *http.Request) string {
+		return r.Header.Get("User")
+	}
+
+	tests := []struct {
+		Name   string
+		Limits ws.Limits
+		User   string
+		Status int
+	}{
+		{"total", ws.Limits{Total: 1}, "bob", http.StatusServiceUnavailable},
+		{"ip", ws.Limits{PerIP: 1}, "bob", http.StatusTooManyRequests},
+		{"principal", ws.Limits{PerPrincipal: 1}, "alice", http.StatusTooManyRequests},
+		{"other principal", ws.Limits{PerPrincipal: 1}, "bob", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			w := ws.NewWS(nil, func(connection *ws.Connection, err error) {},
+				ws.WithLimits(test.Limits), ws.WithPrincipal(principal))
+			w.RegisterHandler("ping", func(r *ws.Request) { r.Respond("pong") })
+
+			c := wstest.Connect(t, w, wstest.WithHeader(http.Header{"User": {"alice"}}))
+			c.Call("ping", nil, nil)
+
+			// plain GET is rejected by upgrader with 400 once admitted
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("User", test.User)
+			// in-memory connections have no remote address
+			req.RemoteAddr = ""
+			rec := httptest.NewRecorder()
+			w.ServeHTTP(rec, req)
+			if rec.Code != test.Status {
+				t.Errorf("status %d; want %d", rec.Code, test.Status)
+			}
+		})
+	}
+}
+
+func TestLimitsForwardedFor(t *testing.T) {
+	// in-memory connections have no remote address, so proxy is the empty address
+	proxy := func(ip string) bool { return ip == "" }
+
+	tests := []struct {
+		Name    string
+		Trusted func(ip string) bool
+		XFF     string
+		Status  int
+	}{
+		{"spoofed header", nil, "2.2.2.2", http.StatusTooManyRequests},
+		{"trusted proxy", proxy, "2.2.2.2", http.StatusBadRequest},
+		{"spoofed by client of trusted proxy", proxy, "2.2.2.2, 1.1.1.1", http.StatusTooManyRequests},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			w := ws.NewWS(nil, func(connection *ws.Connection, err error) {},
+				ws.WithLimits(ws.Limits{PerIP: 1, TrustedProxy: test.Trusted}))
+			w.RegisterHandler("ping", func(r *ws.Request) { r.Respond("pong") })
+
+			c := wstest.Connect(t, w, wstest.WithHeader(http.Header{"X-Forwarded-For": {"1.1.1.1"}}))
+			c.Call("ping", nil, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-Forwarded-For", test.XFF)
+			req.RemoteAddr = ""
+			rec := httptest.NewRecorder()
+			w.ServeHTTP(rec, req)
+			if rec.Code != test.Status {
+				t.Errorf("status %d; want %d", rec.Code, test.Status)
+			}
+		})
+	}
+}
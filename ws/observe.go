@@ -0,0 +1,68 @@
+package ws
+
+import (
+	"time"
+)
+
+// Direction of the frame relative to server
+type Direction string
+
+const (
+	Inbound  Direction = "in"
+	Outbound Direction = "out"
+)
+
+// Frame is raw frame received or sent by connection
+type Frame struct {
+	Time      time.Time
+	Direction Direction
+	Data      []byte
+	// Binary reports whether frame is binary frame, see BinaryFrame
+	Binary bool
+}
+
+// Observe registers fn called for every frame received or sent by the connection,
+// fn is called synchronously and must not retain Data
+func (conn *Connection) Observe(fn func(f Frame)) (cancel func()) {
+	conn.ol.Lock()
+	defer conn.ol.Unlock()
+
+	id := conn.nextObserver
+	conn.nextObserver++
+	if conn.observers == nil {
+		conn.observers = make(map[int]func(Frame))
+	}
+	conn.observers[id] = fn
+
+	return func() {
+		conn.ol.Lock()
+		defer conn.ol.Unlock()
+		delete(conn.observers, id)
+	}
+}
+
+func (conn *Connection) observe(d Direction, data []byte) {
+	conn.observeFrame(Frame{Direction: d, Data: data})
+}
+
+func (conn *Connection) observeBinary(d Direction, frame []byte) {
+	conn.observeFrame(Frame{Direction: d, Data: frame, Binary: true})
+}
+
+func (conn *Connection) observeFrame(f Frame) {
+	conn.ol.Lock()
+	if len(conn.observers) == 0 {
+		conn.ol.Unlock()
+		return
+	}
+	observers := make([]func(Frame), 0, len(conn.observers))
+	for _, fn := range conn.observers {
+		observers = append(observers, fn)
+	}
+	conn.ol.Unlock()
+
+	f.Time = time.Now()
+	for _, fn := range observers {
+		fn(f)
+	}
+}
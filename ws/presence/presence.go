@@ -0,0 +1,340 @@
+// Package presence tracks which principals are connected to ws server.
+//
+// Tracker.PreHook and Tracker.PostHook must be registered on WS configured
+// with ws.WithPrincipal; connections without principal are not tracked.
+package presence
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/IAmRadek/go-kit/ws"
+)
+
+// EventType describes what happened to the principal
+type EventType string
+
+const (
+	// Connect is emitted for every new connection of the principal
+	Connect EventType = "connect"
+	// Disconnect is emitted for every closed connection of the principal
+	Disconnect EventType = "disconnect"
+	// Join is emitted when principal joins the room using Tracker.Join
+	Join EventType = "join"
+	// Leave is emitted when principal leaves the room using Tracker.Leave
+	Leave EventType = "leave"
+)
+
+// Event is delivered to subscribers of Tracker
+type Event struct {
+	Type      EventType
+	Principal string
+	Room      string `json:",omitempty"`
+	// Connections is number of principal's connections after the event,
+	// principal went online when it is 1 on Connect and offline when it is 0 on Disconnect
+	Connections int
+	Time        time.Time
+}
+
+// Status describes presence of the principal
+type Status struct {
+	Principal   string
+	Connections int
+	LastSeen    time.Time
+	// Rooms are current rooms of online principal
+	// or rooms principal was in when it went offline
+	Rooms []string
+}
+
+type user struct {
+	conns     map[*ws.Connection]struct{}
+	lastSeen  time.Time
+	lastRooms []string
+}
+
+// Tracker tracks connections of principals
+type Tracker struct {
+	mu     sync.RWMutex
+	users  map[string]*user
+	swept  time.Time
+	retain time.Duration
+
+	sl   sync.RWMutex
+	next int
+	subs map[int]func(Event)
+
+	now func() time.Time
+}
+
+// Option configures Tracker
+type Option func(t *Tracker)
+
+// WithRetention sets how long offline principals are remembered after they were last seen,
+// 24 hours by default, they are forgotten at most twice as late
+func WithRetention(d time.Duration) Option {
+	return func(t *Tracker) {
+		t.retain = d
+	}
+}
+
+// WithClock sets clock measuring last seen time and retention, ws.SystemClock by default
+func WithClock(clock ws.Clock) Option {
+	return func(t *Tracker) {
+		t.now = clock.Now
+	}
+}
+
+// New returns empty Tracker
+func New(opts ...Option) *Tracker {
+	t := &Tracker{
+		users:  make(map[string]*user),
+		subs:   make(map[int]func(Event)),
+		now:    time.Now,
+		retain: 24 * time.Hour,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// PreHook starts tracking the connection
+func (t *Tracker) PreHook(conn *ws.Connection) {
+	principal := conn.Principal()
+	if principal == "" {
+		return
+	}
+
+	t.mu.Lock()
+	u, ok := t.users[principal]
+	if !ok {
+		u = &user{conns: make(map[*ws.Connection]struct{})}
+		t.users[principal] = u
+	}
+	u.conns[conn] = struct{}{}
+	u.lastSeen = t.now()
+	event := Event{Type: Connect, Principal: principal, Connections: len(u.conns), Time: u.lastSeen}
+	t.mu.Unlock()
+
+	t.emit(event)
+}
+
+// PostHook stops tracking the connection
+func (t *Tracker) PostHook(conn *ws.Connection) {
+	principal := conn.Principal()
+	if principal == "" {
+		return
+	}
+
+	t.mu.Lock()
+	u, ok := t.users[principal]
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+	if _, ok := u.conns[conn]; !ok {
+		t.mu.Unlock()
+		return
+	}
+	if len(u.conns) == 1 {
+		u.lastRooms = rooms(u)
+	}
+	delete(u.conns, conn)
+	u.lastSeen = t.now()
+	event := Event{Type: Disconnect, Principal: principal, Connections: len(u.conns), Time: u.lastSeen}
+	t.sweep(u.lastSeen)
+	t.mu.Unlock()
+
+	t.emit(event)
+}
+
+// sweep forgets principals offline for longer than retention, must be called with t.mu held
+func (t *Tracker) sweep(now time.Time) {
+	if now.Sub(t.swept) < t.retain {
+		return
+	}
+	t.swept = now
+	t.prune(now.Add(-t.retain))
+}
+
+// prune must be called with t.mu held
+func (t *Tracker) prune(before time.Time) int {
+	n := 0
+	for principal, u := range t.users {
+		if len(u.conns) == 0 && !u.lastSeen.After(before) {
+			delete(t.users, principal)
+			n++
+		}
+	}
+	return n
+}
+
+// Prune forgets principals offline since before and returns their number
+func (t *Tracker) Prune(before time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.prune(before)
+}
+
+// Forget forgets offline principal, e.g. deleted user, and reports whether it was remembered
+func (t *Tracker) Forget(principal string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	u, ok := t.users[principal]
+	if !ok || len(u.conns) > 0 {
+		return false
+	}
+	delete(t.users, principal)
+	return true
+}
+
+// Touch updates last seen time of connection's principal,
+// e.g. from handler on every request
+func (t *Tracker) Touch(conn *ws.Connection) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if u, ok := t.users[conn.Principal()]; ok {
+		u.lastSeen = t.now()
+	}
+}
+
+// Join adds connection to the room and notifies subscribers
+func (t *Tracker) Join(conn *ws.Connection, room string) {
+	conn.Join(room)
+	t.roomEvent(conn, Join, room)
+}
+
+// Leave removes connection from the room and notifies subscribers
+func (t *Tracker) Leave(conn *ws.Connection, room string) {
+	conn.Leave(room)
+	t.roomEvent(conn, Leave, room)
+}
+
+func (t *Tracker) roomEvent(conn *ws.Connection, tp EventType, room string) {
+	principal := conn.Principal()
+	if principal == "" {
+		return
+	}
+
+	t.mu.Lock()
+	u, ok := t.users[principal]
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+	u.lastSeen = t.now()
+	event := Event{Type: tp, Principal: principal, Room: room, Connections: len(u.conns), Time: u.lastSeen}
+	t.mu.Unlock()
+
+	t.emit(event)
+}
+
+// IsOnline reports whether principal has at least one connection
+func (t *Tracker) IsOnline(principal string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	u, ok := t.users[principal]
+	return ok && len(u.conns) > 0
+}
+
+// Get returns presence status of the principal
+func (t *Tracker) Get(principal string) (Status, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	u, ok := t.users[principal]
+	if !ok {
+		return Status{}, false
+	}
+
+	status := Status{
+		Principal:   principal,
+		Connections: len(u.conns),
+		LastSeen:    u.lastSeen,
+	}
+	if len(u.conns) > 0 {
+		status.Rooms = rooms(u)
+	} else {
+		// copy, so caller cannot modify rooms kept by tracker
+		status.Rooms = append([]string(nil), u.lastRooms...)
+	}
+	return status, true
+}
+
+// List returns sorted online principals in the room, or all online principals if room is empty
+func (t *Tracker) List(room string) []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var principals []string
+	for principal, u := range t.users {
+		if len(u.conns) == 0 {
+			continue
+		}
+		if room == "" || inRoom(u, room) {
+			principals = append(principals, principal)
+		}
+	}
+	sort.Strings(principals)
+	return principals
+}
+
+// Subscribe registers fn to be called for every event
+func (t *Tracker) Subscribe(fn func(e Event)) (cancel func()) {
+	t.sl.Lock()
+	defer t.sl.Unlock()
+
+	id := t.next
+	t.next++
+	t.subs[id] = fn
+
+	return func() {
+		t.sl.Lock()
+		defer t.sl.Unlock()
+		delete(t.subs, id)
+	}
+}
+
+func (t *Tracker) emit(e Event) {
+	t.sl.RLock()
+	subs := make([]func(Event), 0, len(t.subs))
+	for _, fn := range t.subs {
+		subs = append(subs, fn)
+	}
+	t.sl.RUnlock()
+
+	for _, fn := range subs {
+		fn(e)
+	}
+}
+
+func rooms(u *user) []string {
+	set := make(map[string]struct{})
+	for conn := range u.conns {
+		for _, room := range conn.Rooms() {
+			set[room] = struct{}{}
+		}
+	}
+
+	list := make([]string, 0, len(set))
+	for room := range set {
+		list = append(list, room)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func inRoom(u *user, room string) bool {
+	for conn := range u.conns {
+		for _, r := range conn.Rooms() {
+			if r == room {
+				return true
+			}
+		}
+	}
+	return false
+}
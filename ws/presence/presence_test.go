@@ -0,0 +1,132 @@
+package presence_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/presence"
+	"github.com/IAmRadek/go-kit/ws/wstest"
+	"github.com/gorilla/websocket"
+	memws "github.com/posener/wstest"
+)
+
+func TestTracker(t *testing.T) {
+	tracker := presence.New()
+
+	events := make(chan presence.Event, 10)
+	tracker.Subscribe(func(e presence.Event) {
+		events <- e
+	})
+
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {}, ws.WithPrincipal(func(r *http.Request) string {
+		return r.URL.Query().Get("user")
+	}))
+	w.AddPreHook(tracker.PreHook)
+	w.AddPostHook(tracker.PostHook)
+
+	joined := make(chan struct{})
+	w.RegisterHandler("join", func(r *ws.Request, room string) {
+		tracker.Join(r.C, room)
+		joined <- struct{}{}
+	})
+
+	conn, _, err := memws.NewDialer(w).Dial("ws://example.org/websocket?user=alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.WriteJSON(ws.Request{ID: 1, Topic: "join", Data: []byte(`"lobby"`)})
+	<-joined
+
+	if !tracker.IsOnline("alice") {
+		t.Error("alice should be online")
+	}
+	if got := tracker.List("lobby"); !reflect.DeepEqual(got, []string{"alice"}) {
+		t.Errorf("List(lobby) = %v; want [alice]", got)
+	}
+	if got := tracker.List("other"); len(got) != 0 {
+		t.Errorf("List(other) = %v; want []", got)
+	}
+
+	conn.Close()
+
+	var types []presence.EventType
+	for e := range events {
+		types = append(types, e.Type)
+		if e.Type == presence.Disconnect {
+			break
+		}
+	}
+	want := []presence.EventType{presence.Connect, presence.Join, presence.Disconnect}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("events = %v; want %v", types, want)
+	}
+
+	status, ok := tracker.Get("alice")
+	if !ok {
+		t.Fatal("alice should be known")
+	}
+	if status.Connections != 0 || !reflect.DeepEqual(status.Rooms, []string{"lobby"}) || status.LastSeen.IsZero() {
+		t.Errorf("Get(alice) = %+v; want offline in lobby", status)
+	}
+
+	status.Rooms[0] = "modified"
+	if status, _ := tracker.Get("alice"); !reflect.DeepEqual(status.Rooms, []string{"lobby"}) {
+		t.Errorf("Get(alice) after modifying returned rooms = %+v; want offline in lobby", status)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	clock := wstest.NewClock(time.Unix(0, 0))
+	tracker := presence.New(presence.WithRetention(time.Hour), presence.WithClock(clock))
+
+	disconnected := make(chan string, 10)
+	tracker.Subscribe(func(e presence.Event) {
+		if e.Type == presence.Disconnect {
+			disconnected <- e.Principal
+		}
+	})
+
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {}, ws.WithPrincipal(func(r *http.Request) string {
+		return r.URL.Query().Get("user")
+	}))
+	w.AddPreHook(tracker.PreHook)
+	w.AddPostHook(tracker.PostHook)
+
+	connect := func(user string) *websocket.Conn {
+		conn, _, err := memws.NewDialer(w).Dial("ws://example.org/websocket?user="+user, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	session := func(user string) {
+		connect(user).Close()
+		<-disconnected
+	}
+
+	carol := connect("carol")
+	defer carol.Close()
+	session("alice")
+	if tracker.Forget("carol") {
+		t.Error("online carol forgotten")
+	}
+
+	clock.Advance(time.Hour)
+	session("bob")
+	if _, ok := tracker.Get("alice"); ok {
+		t.Error("alice remembered after retention")
+	}
+	if _, ok := tracker.Get("bob"); !ok {
+		t.Error("bob should be known")
+	}
+	if !tracker.Forget("bob") {
+		t.Error("offline bob not forgotten")
+	}
+	if _, ok := tracker.Get("bob"); ok {
+		t.Error("bob remembered after Forget")
+	}
+}
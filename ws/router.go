@@ -0,0 +1,320 @@
+package ws
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Middleware wraps handler, e.g. with authorization or logging
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Router registers handlers for topics.
+//
+// Topics are dot separated segments, patterns may contain
+// parameter segments ("room.{id}.message") matching single segment,
+// available as Request.Param("id"), and "*" segments matching single segment
+// or, as the last segment ("admin.*"), all remaining segments,
+// available as Request.Param("*").
+type Router interface {
+	// RegisterHandler register function for entered topic
+	// note that handlerFn is interface{} but should be function (func(r *Request, optionalParameter string))
+	RegisterHandler(topic string, handlerFn interface{})
+
+	// ReplaceHandler registers handlerFn for topic, replacing already registered handler,
+	// it is safe to call while connections are served
+	ReplaceHandler(topic string, handlerFn interface{})
+
+	// UnregisterHandler removes handler of topic, requests for the topic are passed to fallback,
+	// it is safe to call while connections are served
+	UnregisterHandler(topic string)
+
+	// Use appends middleware wrapping every handler of the router,
+	// it is safe to call while connections are served
+	Use(mw ...Middleware)
+
+	// Group returns router registering handlers under topic prefix, e.g. "chat."
+	Group(prefix string, mw ...Middleware) Router
+}
+
+type group struct {
+	ws     *ws
+	parent *group
+	prefix string
+}
+
+func (g *group) RegisterHandler(topic string, handlerFn interface{}) {
+	g.handle(g.prefix+topic, handlerFn, false)
+}
+
+func (g *group) ReplaceHandler(topic string, handlerFn interface{}) {
+	g.handle(g.prefix+topic, handlerFn, true)
+}
+
+// handle registers handlerFn for topic without prefix of the group,
+// middleware of the group and its parents is applied
+func (g *group) handle(topic string, handlerFn interface{}, replace bool) {
+	h := newHandler(handlerFn)
+	g.ws.routes.update(func(rs *routes) {
+		if replace {
+			rs.remove(topic)
+		}
+		rs.add(topic, g, h)
+		rs.info[topic] = HandlerInfo{Topic: topic, In: handlerInput(handlerFn)}
+	})
+}
+
+// setOutput records type of response data of handler registered for topic without prefix of the group
+func (g *group) setOutput(topic string, out reflect.Type) {
+	g.ws.routes.update(func(rs *routes) {
+		if info, ok := rs.info[topic]; ok {
+			info.Out = out
+			rs.info[topic] = info
+		}
+	})
+}
+
+func (g *group) UnregisterHandler(topic string) {
+	g.ws.routes.update(func(rs *routes) {
+		rs.remove(g.prefix + topic)
+	})
+}
+
+func (g *group) Use(mw ...Middleware) {
+	if len(mw) == 0 {
+		return
+	}
+	g.ws.routes.update(func(rs *routes) {
+		cur := rs.mw[g]
+		rs.mw[g] = append(cur[:len(cur):len(cur)], mw...)
+		rs.rebuild(g)
+	})
+}
+
+func (g *group) Group(prefix string, mw ...Middleware) Router {
+	child := &group{
+		ws:     g.ws,
+		parent: g,
+		prefix: g.prefix + prefix,
+	}
+	child.Use(mw...)
+	return child
+}
+
+// within reports whether g is ancestor or one of its subgroups
+func (g *group) within(ancestor *group) bool {
+	for cur := g; cur != nil; cur = cur.parent {
+		if cur == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	segmentLiteral = iota
+	segmentParam
+	segmentWildcard
+)
+
+type route struct {
+	pattern  string
+	segments []string
+	// handler is base wrapped with middleware of group and its parents
+	handler HandlerFunc
+	base    HandlerFunc
+	group   *group
+}
+
+type routes struct {
+	exact    map[string]route
+	patterns []route
+	info     map[string]HandlerInfo
+	// mw is middleware of groups, appended slices are never modified in place
+	mw       map[*group][]Middleware
+	fallback route
+}
+
+// routeTable is copy-on-write routes, so requests are matched without locking
+// while handlers are registered
+type routeTable struct {
+	mu sync.Mutex
+	v  atomic.Value
+}
+
+func (t *routeTable) load() *routes {
+	rs, _ := t.v.Load().(*routes)
+	if rs == nil {
+		return &routes{}
+	}
+	return rs
+}
+
+// update applies fn to copy of routes and publishes it
+func (t *routeTable) update(fn func(rs *routes)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rs := t.load().clone()
+	fn(rs)
+	t.v.Store(rs)
+}
+
+func (rs *routes) clone() *routes {
+	c := &routes{
+		exact:    make(map[string]route, len(rs.exact)),
+		patterns: append([]route(nil), rs.patterns...),
+		info:     make(map[string]HandlerInfo, len(rs.info)),
+		mw:       make(map[*group][]Middleware, len(rs.mw)),
+		fallback: rs.fallback,
+	}
+	for g, mw := range rs.mw {
+		c.mw[g] = mw
+	}
+	for topic, r := range rs.exact {
+		c.exact[topic] = r
+	}
+	for topic, info := range rs.info {
+		c.info[topic] = info
+	}
+	return c
+}
+
+func isPattern(topic string) bool {
+	return strings.Contains(topic, "{") || topic == "*" || strings.HasPrefix(topic, "*.") ||
+		strings.HasSuffix(topic, ".*") || strings.Contains(topic, ".*.")
+}
+
+func segmentKind(segment string) int {
+	switch {
+	case segment == "*":
+		return segmentWildcard
+	case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"):
+		return segmentParam
+	default:
+		return segmentLiteral
+	}
+}
+
+// chain wraps h with middleware of g and its parents, so it is built once
+// when handler is registered or middleware of the group changes
+func (rs *routes) chain(g *group, h HandlerFunc) HandlerFunc {
+	for cur := g; cur != nil; cur = cur.parent {
+		for i := len(rs.mw[cur]) - 1; i >= 0; i-- {
+			h = rs.mw[cur][i](h)
+		}
+	}
+	return h
+}
+
+// rebuild chains handlers of group g and its subgroups again
+func (rs *routes) rebuild(g *group) {
+	for topic, r := range rs.exact {
+		if r.group.within(g) {
+			r.handler = rs.chain(r.group, r.base)
+			rs.exact[topic] = r
+		}
+	}
+	for i, r := range rs.patterns {
+		if r.group.within(g) {
+			rs.patterns[i].handler = rs.chain(r.group, r.base)
+		}
+	}
+	if rs.fallback.base != nil && rs.fallback.group.within(g) {
+		rs.fallback.handler = rs.chain(rs.fallback.group, rs.fallback.base)
+	}
+}
+
+func (rs *routes) add(topic string, g *group, h HandlerFunc) {
+	r := route{pattern: topic, handler: rs.chain(g, h), base: h, group: g}
+	if !isPattern(topic) {
+		if rs.exact == nil {
+			rs.exact = make(map[string]route)
+		}
+		if _, ok := rs.exact[topic]; ok {
+			panic("topic already registered")
+		}
+		rs.exact[topic] = r
+		return
+	}
+
+	for _, r := range rs.patterns {
+		if r.pattern == topic {
+			panic("topic already registered")
+		}
+	}
+
+	r.segments = strings.Split(topic, ".")
+	rs.patterns = append(rs.patterns, r)
+	sort.SliceStable(rs.patterns, func(i, j int) bool {
+		return moreSpecific(rs.patterns[i].segments, rs.patterns[j].segments)
+	})
+}
+
+func (rs *routes) remove(topic string) {
+	delete(rs.exact, topic)
+	delete(rs.info, topic)
+	for i, r := range rs.patterns {
+		if r.pattern == topic {
+			rs.patterns = append(rs.patterns[:i], rs.patterns[i+1:]...)
+			return
+		}
+	}
+}
+
+// moreSpecific orders literal segments before parameters and parameters before wildcards
+func moreSpecific(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		ka, kb := segmentKind(a[i]), segmentKind(b[i])
+		if ka != kb {
+			return ka < kb
+		}
+	}
+	return len(a) > len(b)
+}
+
+func (rs *routes) match(topic string) (route, map[string]string, bool) {
+	if r, ok := rs.exact[topic]; ok {
+		return r, nil, true
+	}
+
+	segments := strings.Split(topic, ".")
+	for _, r := range rs.patterns {
+		if params, ok := r.match(segments); ok {
+			return r, params, true
+		}
+	}
+	return route{}, nil, false
+}
+
+func (r route) match(segments []string) (map[string]string, bool) {
+	var params map[string]string
+	for i, pattern := range r.segments {
+		if i >= len(segments) {
+			return nil, false
+		}
+
+		switch segmentKind(pattern) {
+		case segmentLiteral:
+			if pattern != segments[i] {
+				return nil, false
+			}
+		case segmentParam:
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[pattern[1:len(pattern)-1]] = segments[i]
+		case segmentWildcard:
+			if i == len(r.segments)-1 {
+				if params == nil {
+					params = make(map[string]string)
+				}
+				params["*"] = strings.Join(segments[i:], ".")
+				return params, true
+			}
+		}
+	}
+	return params, len(segments) == len(r.segments)
+}
@@ -0,0 +1,152 @@
+package ws_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/wstest"
+)
+
+func TestRouter(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+
+	var calls []string
+	trace := func(name string) ws.Middleware {
+		return func(next ws.HandlerFunc) ws.HandlerFunc {
+			return func(r *ws.Request) error {
+				calls = append(calls, name)
+				return next(r)
+			}
+		}
+	}
+
+	w.Use(trace("root"))
+	chat := w.Group("chat.", trace("chat"))
+	chat.RegisterHandler("room.{id}.message", func(r *ws.Request, text string) {
+		r.Respond(r.Param("id") + ":" + text)
+	})
+	chat.RegisterHandler("room.lobby.message", func(r *ws.Request, text string) {
+		r.Respond("lobby:" + text)
+	})
+	w.RegisterHandler("admin.*", func(r *ws.Request) {
+		r.Respond("admin:" + r.Param("*"))
+	})
+	w.SetFallback(func(r *ws.Request) {
+		r.Respond("fallback:" + r.Topic)
+	})
+
+	tests := []struct {
+		Topic   string
+		Payload interface{}
+		Want    string
+		Calls   []string
+	}{
+		{"chat.room.42.message", "hi", "42:hi", []string{"root", "chat"}},
+		{"chat.room.lobby.message", "hi", "lobby:hi", []string{"root", "chat"}},
+		{"admin.users.ban", nil, "admin:users.ban", []string{"root"}},
+		{"admin", nil, "fallback:admin", []string{"root"}},
+		{"chat.room.42", nil, "fallback:chat.room.42", []string{"root"}},
+	}
+
+	c := wstest.Connect(t, w)
+	for _, test := range tests {
+		calls = nil
+
+		var resp string
+		c.Call(test.Topic, test.Payload, &resp)
+		if resp != test.Want {
+			t.Errorf("%s = %q; want %q", test.Topic, resp, test.Want)
+		}
+		if !reflect.DeepEqual(calls, test.Calls) {
+			t.Errorf("%s middleware = %v; want %v", test.Topic, calls, test.Calls)
+		}
+	}
+}
+
+func TestRuntimeHandlers(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	w.RegisterHandler("feature", func(r *ws.Request) { r.Respond("v1") })
+	w.RegisterHandler("plugin.{name}", func(r *ws.Request) { r.Respond(r.Param("name")) })
+
+	c := wstest.Connect(t, w)
+
+	var got string
+	c.Call("feature", nil, &got)
+	if got != "v1" {
+		t.Errorf("got %q; want v1", got)
+	}
+
+	// swap handlers while requests are served
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			w.ReplaceHandler("feature", func(r *ws.Request) { r.Respond("v2") })
+			w.Use(func(next ws.HandlerFunc) ws.HandlerFunc { return next })
+			w.SetFallback(func(r *ws.Request) { r.Respond("fallback") })
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		c.Call("feature", nil, &got)
+		c.Call("missing", nil, nil)
+	}
+	<-done
+
+	c.Call("feature", nil, &got)
+	if got != "v2" {
+		t.Errorf("got %q; want v2", got)
+	}
+
+	w.SetFallback(func(r *ws.Request) error { return ws.ErrUnknownHandler })
+	w.UnregisterHandler("feature")
+	w.UnregisterHandler("plugin.{name}")
+	for _, topic := range []string{"feature", "plugin.x"} {
+		var wsErr *ws.Error
+		if err := c.TryCall(topic, nil, nil); !errors.As(err, &wsErr) || wsErr.Code != ws.CodeUnknownTopic {
+			t.Errorf("%s: got %v; want unknown topic", topic, err)
+		}
+	}
+
+	w.RegisterHandler("feature", func(r *ws.Request) { r.Respond("v3") })
+	c.Call("feature", nil, &got)
+	if got != "v3" {
+		t.Errorf("got %q; want v3", got)
+	}
+}
+
+func TestMiddlewareState(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+
+	// limit keeps count of calls in its chain, so it must be built once per handler
+	limit := func(n int) ws.Middleware {
+		return func(next ws.HandlerFunc) ws.HandlerFunc {
+			calls := 0
+			return func(r *ws.Request) error {
+				calls++
+				if calls > n {
+					return errors.New("limited")
+				}
+				return next(r)
+			}
+		}
+	}
+
+	chat := w.Group("chat.")
+	chat.RegisterHandler("send", func(r *ws.Request) { r.Respond("sent") })
+	w.RegisterHandler("ping", func(r *ws.Request) { r.Respond("pong") })
+	// middleware added after registration applies to handlers of the group only
+	chat.Use(limit(3))
+
+	c := wstest.Connect(t, w)
+	for i := 0; i < 5; i++ {
+		err := c.TryCall("chat.send", nil, nil)
+		if limited := i >= 3; (err != nil) != limited {
+			t.Errorf("call %d: got %v; want limited %t", i, err, limited)
+		}
+		if err := c.TryCall("ping", nil, nil); err != nil {
+			t.Errorf("ping %d: %v", i, err)
+		}
+	}
+}
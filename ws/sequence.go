@@ -0,0 +1,218 @@
+package ws
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// ResyncTopic is topic of requests fetching pushes missed by client, see WithSequences
+const ResyncTopic = "ws.resync"
+
+// CodeResyncUnavailable is sent when missed pushes are no longer kept
+const CodeResyncUnavailable ErrorCode = "resync_unavailable"
+
+// SequenceOptions configures numbering of pushes
+type SequenceOptions struct {
+	// Topics are push topics numbered per connection, stream of the push is the topic.
+	// Streams of topics belong to the connection and are dropped when it is closed,
+	// so pushes missed before reconnect can not be resynced, use rooms for such pushes.
+	Topics []string
+	// Rooms numbers broadcasts per room, stream of the push is "room:" followed by room name.
+	// Numbering starts again from 1 when room is emptied, and is independent on every instance.
+	// Numbered broadcasts are queued for every connection, so slow connection does not delay others,
+	// pushes exceeding History are dropped from the queue and client sees the gap.
+	Rooms bool
+	// History is number of recent pushes of every stream kept for resync, 256 by default
+	History int
+}
+
+// WithSequences numbers pushes of selected topics and rooms, so clients can detect gaps
+// and fetch missed pushes with ResyncTopic request carrying ResyncRequest.
+// Numbered pushes of Native protocol carry Seq and Stream fields,
+// pushes of every stream are written in order of their numbers.
+func WithSequences(opts SequenceOptions) Option {
+	return func(m *ws) {
+		if opts.History == 0 {
+			opts.History = 256
+		}
+		topics := make(map[string]struct{}, len(opts.Topics))
+		for _, topic := range opts.Topics {
+			topics[topic] = struct{}{}
+		}
+		m.sequences = &sequences{opts: opts, topics: topics, rooms: make(map[string]*sequence)}
+		m.RegisterHandler(ResyncTopic, m.resync)
+	}
+}
+
+// ResyncRequest is payload of ResyncTopic request, response is array of pushes of the stream numbered after After
+type ResyncRequest struct {
+	Stream string
+	After  int64
+}
+
+type sequences struct {
+	opts   SequenceOptions
+	topics map[string]struct{}
+	rooms  map[string]*sequence
+}
+
+// sequence numbers pushes of single stream
+type sequence struct {
+	mu      sync.Mutex
+	last    int64
+	history []response
+	size    int
+}
+
+// send numbers resp and writes it with write while holding the lock, so pushes are written in order,
+// data is encoded first, so changes of data after the push are not resynced
+func (s *sequence) send(stream string, resp response, write func(resp response) error) error {
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		return err
+	}
+	resp.Data = json.RawMessage(data)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.last++
+	resp.Seq, resp.Stream = s.last, stream
+	s.history = append(s.history, resp)
+	if len(s.history) > s.size {
+		s.history = s.history[1:]
+	}
+	return write(resp)
+}
+
+// after returns pushes numbered after seq, false if some of them are no longer kept
+func (s *sequence) after(seq int64) ([]response, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if seq >= s.last {
+		return nil, true
+	}
+	if len(s.history) == 0 || s.history[0].Seq > seq+1 {
+		return nil, false
+	}
+	return append([]response(nil), s.history[seq+1-s.history[0].Seq:]...), true
+}
+
+// topicSequence returns sequence of the topic of the connection, nil if topic is not numbered
+func (conn *Connection) topicSequence(topic string) *sequence {
+	seqs := conn.ws.sequences
+	if seqs == nil {
+		return nil
+	}
+	if _, ok := seqs.topics[topic]; !ok {
+		return nil
+	}
+
+	conn.seql.Lock()
+	defer conn.seql.Unlock()
+	if conn.seqs == nil {
+		conn.seqs = make(map[string]*sequence)
+	}
+	s, ok := conn.seqs[topic]
+	if !ok {
+		s = &sequence{size: seqs.opts.History}
+		conn.seqs[topic] = s
+	}
+	return s
+}
+
+// roomSequence returns sequence of the room, nil if rooms are not numbered or room is empty,
+// must be called with m.mu held
+func (m *ws) roomSequence(room string) *sequence {
+	if m.sequences == nil || !m.sequences.opts.Rooms || len(m.rooms[room]) == 0 {
+		return nil
+	}
+	s, ok := m.sequences.rooms[room]
+	if !ok {
+		s = &sequence{size: m.sequences.opts.History}
+		m.sequences.rooms[room] = s
+	}
+	return s
+}
+
+// outbox queues numbered pushes of the connection and writes them in order
+type outbox struct {
+	mu      sync.Mutex
+	queue   []response
+	size    int
+	running bool
+}
+
+// enqueue queues resp to be written without waiting for the connection,
+// the oldest push is dropped when the queue is full
+func (conn *Connection) enqueue(resp response) {
+	conn.seql.Lock()
+	if conn.outbox == nil {
+		conn.outbox = &outbox{size: conn.ws.sequences.opts.History}
+	}
+	o := conn.outbox
+	conn.seql.Unlock()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.queue) == o.size {
+		o.queue = o.queue[1:]
+	}
+	o.queue = append(o.queue, resp)
+	if !o.running {
+		o.running = true
+		go conn.drain(o)
+	}
+}
+
+// drain writes queued pushes until the queue is empty, pushes of closed connection are dropped
+func (conn *Connection) drain(o *outbox) {
+	for {
+		o.mu.Lock()
+		if len(o.queue) == 0 || conn.ctx.Err() != nil {
+			o.queue = nil
+			o.running = false
+			o.mu.Unlock()
+			return
+		}
+		resp := o.queue[0]
+		o.queue = o.queue[1:]
+		o.mu.Unlock()
+
+		if err := conn.send(resp); err != nil {
+			conn.ws.reportError(conn, err)
+		}
+	}
+}
+
+// resync responds with pushes of the stream missed by client
+func (m *ws) resync(r *Request, req ResyncRequest) error {
+	var s *sequence
+	if strings.HasPrefix(req.Stream, "room:") {
+		room := strings.TrimPrefix(req.Stream, "room:")
+		m.mu.Lock()
+		if _, member := r.C.rooms[room]; member {
+			s = m.sequences.rooms[room]
+		}
+		m.mu.Unlock()
+	} else {
+		r.C.seql.Lock()
+		s = r.C.seqs[req.Stream]
+		r.C.seql.Unlock()
+	}
+	if s == nil {
+		return &Error{Code: CodeResyncUnavailable, Message: "unknown stream"}
+	}
+
+	resps, ok := s.after(req.After)
+	if !ok {
+		return &Error{Code: CodeResyncUnavailable, Message: "missed pushes are no longer kept"}
+	}
+	pushes := make([]interface{}, 0, len(resps))
+	for _, resp := range resps {
+		pushes = append(pushes, r.C.codec.encode(resp))
+	}
+	return r.Respond(pushes)
+}
@@ -0,0 +1,167 @@
+package ws_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/client"
+	"github.com/IAmRadek/go-kit/ws/wstest"
+	"github.com/gorilla/websocket"
+	memws "github.com/posener/wstest"
+)
+
+func TestTopicSequences(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {},
+		ws.WithSequences(ws.SequenceOptions{Topics: []string{"ticks"}, History: 2}))
+	w.RegisterHandler("tick", func(r *ws.Request, n int) {
+		for i := 1; i <= n; i++ {
+			// changes after the push are not resynced
+			data := []int{i}
+			r.C.Send(0, "ticks", data)
+			data[0] = -1
+			r.C.Send(0, "other", i)
+		}
+		r.Respond("ok")
+	})
+
+	c := wstest.Connect(t, w)
+	var status string
+	c.Call("tick", 3, &status)
+
+	for i := int64(1); i <= 3; i++ {
+		if msg := c.Next(); msg.Seq != i || msg.Stream != "ticks" {
+			t.Errorf("got seq %d of %q; want %d of ticks", msg.Seq, msg.Stream, i)
+		}
+		if msg := c.Next(); msg.Seq != 0 || msg.Stream != "" {
+			t.Errorf("push of not numbered topic got seq %d of %q", msg.Seq, msg.Stream)
+		}
+	}
+	if last := c.LastSeq("ticks"); last != 3 {
+		t.Errorf("last seq %d; want 3", last)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), wstest.Timeout)
+	defer cancel()
+
+	msgs, err := c.Resync(ctx, "ticks", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].Seq != 2 || msgs[1].Seq != 3 || string(msgs[1].Data) != "[3]" {
+		t.Errorf("resync after 1 returned %+v; want pushes 2 and 3", msgs)
+	}
+
+	var wsErr *ws.Error
+	if _, err := c.Resync(ctx, "ticks", 0); !errors.As(err, &wsErr) || wsErr.Code != ws.CodeResyncUnavailable {
+		t.Errorf("resync of evicted pushes returned %v; want %s", err, ws.CodeResyncUnavailable)
+	}
+	if _, err := c.Resync(ctx, "unknown", 0); !errors.As(err, &wsErr) || wsErr.Code != ws.CodeResyncUnavailable {
+		t.Errorf("resync of unknown stream returned %v; want %s", err, ws.CodeResyncUnavailable)
+	}
+}
+
+func TestRoomSequences(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {}, ws.WithSequences(ws.SequenceOptions{Rooms: true}))
+	w.RegisterHandler("join", func(r *ws.Request) {
+		r.C.Join("lobby")
+		r.Respond("ok")
+	})
+	w.RegisterHandler("leave", func(r *ws.Request) {
+		r.C.Leave("lobby")
+		r.Respond("ok")
+	})
+
+	var status string
+	a := wstest.Connect(t, w)
+	a.Call("join", nil, &status)
+	b := wstest.Connect(t, w)
+	b.Call("join", nil, &status)
+
+	gaps := make(chan client.Gap, 1)
+	a.OnGap(func(g client.Gap) { gaps <- g })
+
+	// in-memory sockets are unbuffered, so broadcasting must run concurrently with reads
+	broadcast := func(n int) {
+		go w.Broadcast("lobby", "news", n)
+	}
+
+	broadcast(1)
+	for _, c := range []*wstest.Client{a, b} {
+		if msg := c.Next(); msg.Seq != 1 || msg.Stream != "room:lobby" {
+			t.Errorf("got seq %d of %q; want 1 of room:lobby", msg.Seq, msg.Stream)
+		}
+	}
+
+	a.Call("leave", nil, &status)
+	broadcast(2)
+	b.Next()
+	a.Call("join", nil, &status)
+	broadcast(3)
+	b.Next()
+	if msg := a.Next(); msg.Seq != 3 {
+		t.Errorf("got seq %d; want 3", msg.Seq)
+	}
+
+	select {
+	case g := <-gaps:
+		if g != (client.Gap{Stream: "room:lobby", After: 1, Before: 3}) {
+			t.Errorf("got gap %+v; want room:lobby between 1 and 3", g)
+		}
+	case <-time.After(wstest.Timeout):
+		t.Fatal("gap not reported")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), wstest.Timeout)
+	defer cancel()
+	msgs, err := a.Resync(ctx, "room:lobby", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].Seq != 2 || string(msgs[0].Data) != "2" {
+		t.Errorf("resync after 1 returned %+v; want pushes 2 and 3", msgs)
+	}
+}
+
+func TestRoomSequencesSlowConnection(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {}, ws.WithSequences(ws.SequenceOptions{Rooms: true}))
+	w.RegisterHandler("join", func(r *ws.Request) {
+		r.C.Join("lobby")
+		r.Respond("ok")
+	})
+
+	var status string
+	a := wstest.Connect(t, w)
+	a.Call("join", nil, &status)
+
+	// slow connection stops reading after it joined
+	slow, _, err := memws.NewDialer(w).Dial("ws://example.org/websocket", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.Close()
+	slow.WriteMessage(websocket.TextMessage, []byte(`{"ID": 1, "Topic": "join"}`))
+	if _, _, err := slow.ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 3; i++ {
+			w.Broadcast("lobby", "news", i)
+		}
+	}()
+	for i := int64(1); i <= 3; i++ {
+		if msg := a.Next(); msg.Seq != i {
+			t.Errorf("got seq %d; want %d", msg.Seq, i)
+		}
+	}
+	select {
+	case <-done:
+	case <-time.After(wstest.Timeout):
+		t.Fatal("broadcast waits for slow connection")
+	}
+}
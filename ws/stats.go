@@ -0,0 +1,57 @@
+package ws
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// fallbackTopic is pattern under which requests handled by fallback are counted
+const fallbackTopic = "(fallback)"
+
+// TopicStats are counters of requests handled by handler of the topic pattern
+type TopicStats struct {
+	Topic    string        `json:"topic"`
+	Requests int64         `json:"requests"`
+	Errors   int64         `json:"errors"`
+	Duration time.Duration `json:"duration"`
+}
+
+type topicStats struct {
+	mu     sync.Mutex
+	topics map[string]*TopicStats
+}
+
+func (s *topicStats) record(pattern string, d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.topics == nil {
+		s.topics = make(map[string]*TopicStats)
+	}
+	st, ok := s.topics[pattern]
+	if !ok {
+		st = &TopicStats{Topic: pattern}
+		s.topics[pattern] = st
+	}
+	st.Requests++
+	st.Duration += d
+	if err != nil {
+		st.Errors++
+	}
+}
+
+// list returns copy of stats sorted by topic
+func (s *topicStats) list() []TopicStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]TopicStats, 0, len(s.topics))
+	for _, st := range s.topics {
+		list = append(list, *st)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Topic < list[j].Topic
+	})
+	return list
+}
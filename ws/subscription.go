@@ -0,0 +1,225 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Filter selects events published to channel which are delivered to subscription,
+// event is the value passed to Publish or json.RawMessage if it was published by other instance
+type Filter func(event interface{}) bool
+
+// FilterOf returns Filter calling fn with events of type T,
+// events published by other instances are decoded into T, events of other types are skipped
+func FilterOf[T any](fn func(event T) bool) Filter {
+	return func(event interface{}) bool {
+		switch e := event.(type) {
+		case T:
+			return fn(e)
+		case json.RawMessage:
+			var v T
+			if err := json.Unmarshal(e, &v); err != nil {
+				return false
+			}
+			return fn(v)
+		default:
+			return false
+		}
+	}
+}
+
+// Subscription delivers events published to channel as responses
+// with ID and topic of the request which created it
+type Subscription struct {
+	// ID is ID of the request, zero for JSON-RPC requests with string ID
+	ID      int64
+	Channel string
+
+	conn   *Connection
+	key    string
+	topic  string
+	rawID  json.RawMessage
+	filter Filter
+
+	once sync.Once
+	done chan struct{}
+}
+
+// Subscribe registers subscription to events published to channel and bound to request ID,
+// matching events are sent until the subscription is canceled, unsubscribed by ID or connection is closed,
+// subscription of closed connection is returned canceled.
+// Subscription with the same ID on the connection is replaced, nil filter matches every event.
+// Subscriptions created by notifications, which have no ID, are never replaced.
+func (r *Request) Subscribe(channel string, filter Filter) *Subscription {
+	s := &Subscription{
+		ID:      r.ID,
+		Channel: channel,
+		conn:    r.C,
+		topic:   r.Topic,
+		rawID:   r.rawID,
+		filter:  filter,
+		done:    make(chan struct{}),
+	}
+
+	r.C.subl.Lock()
+	if r.C.subsClosed {
+		// connection is closed, e.g. handler goroutine subscribed late
+		r.C.subl.Unlock()
+		s.once.Do(func() { close(s.done) })
+		return s
+	}
+	if r.C.subs == nil {
+		r.C.subs = make(map[string]*Subscription)
+	}
+	if r.rawID == nil && r.ID == 0 {
+		r.C.subSeq++
+		s.key = "#" + strconv.FormatInt(r.C.subSeq, 10)
+	} else {
+		s.key = subscriptionKey(r.ID, r.rawID)
+	}
+	prev := r.C.subs[s.key]
+	r.C.subs[s.key] = s
+
+	// subscription is added to channel before cancelSubscriptions can see it
+	m := r.C.ws
+	m.mu.Lock()
+	if m.channels[channel] == nil {
+		m.channels[channel] = make(map[*Subscription]struct{})
+	}
+	m.channels[channel][s] = struct{}{}
+	m.mu.Unlock()
+	r.C.subl.Unlock()
+
+	if prev != nil {
+		prev.Cancel()
+	}
+
+	return s
+}
+
+// Done is closed when subscription is canceled
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Cancel stops delivery of events
+func (s *Subscription) Cancel() {
+	s.once.Do(func() {
+		close(s.done)
+
+		s.conn.subl.Lock()
+		if s.conn.subs[s.key] == s {
+			delete(s.conn.subs, s.key)
+		}
+		s.conn.subl.Unlock()
+
+		m := s.conn.ws
+		m.mu.Lock()
+		delete(m.channels[s.Channel], s)
+		if len(m.channels[s.Channel]) == 0 {
+			delete(m.channels, s.Channel)
+		}
+		m.mu.Unlock()
+	})
+}
+
+func (s *Subscription) deliver(event interface{}) error {
+	if s.filter != nil && !s.filter(event) {
+		return nil
+	}
+	return s.conn.send(response{ID: s.ID, Topic: s.topic, Data: event, rawID: s.rawID})
+}
+
+// subscriptionKey identifies subscription by ID of the request as sent by client,
+// so JSON-RPC requests with string IDs have distinct subscriptions
+func subscriptionKey(id int64, rawID json.RawMessage) string {
+	if rawID != nil {
+		return string(bytes.TrimSpace(rawID))
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+// Unsubscribe cancels subscription created by request with the ID
+// and reports whether it was active, e.g. in handler of "unsubscribe" topic
+func (conn *Connection) Unsubscribe(id int64) bool {
+	return conn.unsubscribe(strconv.FormatInt(id, 10))
+}
+
+// UnsubscribeID cancels subscription created by request with the ID as sent by client,
+// e.g. JSON-RPC string ID, and reports whether it was active
+func (conn *Connection) UnsubscribeID(id json.RawMessage) bool {
+	return conn.unsubscribe(subscriptionKey(0, id))
+}
+
+func (conn *Connection) unsubscribe(key string) bool {
+	conn.subl.Lock()
+	s, ok := conn.subs[key]
+	conn.subl.Unlock()
+
+	if ok {
+		s.Cancel()
+	}
+	return ok
+}
+
+// Subscriptions returns active subscriptions of the connection sorted by ID
+func (conn *Connection) Subscriptions() []*Subscription {
+	conn.subl.Lock()
+	subs := make([]*Subscription, 0, len(conn.subs))
+	for _, s := range conn.subs {
+		subs = append(subs, s)
+	}
+	conn.subl.Unlock()
+
+	sort.Slice(subs, func(i, j int) bool {
+		if subs[i].ID != subs[j].ID {
+			return subs[i].ID < subs[j].ID
+		}
+		return subs[i].key < subs[j].key
+	})
+	return subs
+}
+
+// cancelSubscriptions is called when connection is closed
+func (conn *Connection) cancelSubscriptions() {
+	conn.subl.Lock()
+	conn.subsClosed = true
+	conn.subl.Unlock()
+
+	for _, s := range conn.Subscriptions() {
+		s.Cancel()
+	}
+}
+
+// Publish delivers event to subscriptions of the channel accepted by their filters,
+// including subscriptions of other instances sharing the Broker
+func (m *ws) Publish(channel string, event interface{}) error {
+	m.publishLocal(channel, event)
+
+	if m.broker == nil {
+		return nil
+	}
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return m.broker.Publish(Message{Origin: m.id, Channel: channel, Data: raw})
+}
+
+func (m *ws) publishLocal(channel string, event interface{}) {
+	m.mu.RLock()
+	subs := make([]*Subscription, 0, len(m.channels[channel]))
+	for s := range m.channels[channel] {
+		subs = append(subs, s)
+	}
+	m.mu.RUnlock()
+
+	for _, s := range subs {
+		if err := s.deliver(event); err != nil {
+			m.reportError(s.conn, err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package ws_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/wstest"
+	"github.com/gorilla/websocket"
+	memws "github.com/posener/wstest"
+)
+
+type price struct {
+	Symbol string
+	Value  int
+}
+
+func TestSubscriptions(t *testing.T) {
+	broker := ws.NewMemoryBroker()
+	instanceA := ws.NewWS(nil, func(connection *ws.Connection, err error) {}, ws.WithBroker(broker))
+	instanceB := ws.NewWS(nil, func(connection *ws.Connection, err error) {}, ws.WithBroker(broker))
+
+	subs := make(chan *ws.Subscription, 1)
+	instanceA.RegisterHandler("prices.subscribe", func(r *ws.Request, symbol string) {
+		subs <- r.Subscribe("prices", ws.FilterOf(func(p price) bool {
+			return p.Symbol == symbol
+		}))
+		r.Respond("subscribed")
+	})
+	instanceA.RegisterHandler("unsubscribe", func(r *ws.Request, id int64) {
+		r.Respond(r.C.Unsubscribe(id))
+	})
+
+	c := wstest.Connect(t, instanceA)
+	var status string
+	c.Call("prices.subscribe", "A", &status)
+	sub := <-subs
+
+	// in-memory sockets are unbuffered, so publishing must run concurrently with reads
+	published := make(chan error, 3)
+	go func() {
+		published <- instanceA.Publish("prices", price{"A", 1})
+		published <- instanceA.Publish("prices", price{"B", 2})
+		published <- instanceB.Publish("prices", price{"A", 3})
+	}()
+
+	for _, want := range []int{1, 3} {
+		msg := c.Next()
+		var got price
+		msg.Decode(&got)
+		if msg.ID != sub.ID || msg.Topic != "prices.subscribe" || got.Value != want {
+			t.Errorf("got %d %s %+v; want %d prices.subscribe %d", msg.ID, msg.Topic, got, sub.ID, want)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if err := <-published; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ok bool
+	c.Call("unsubscribe", sub.ID, &ok)
+	if !ok {
+		t.Errorf("unsubscribe of active subscription returned false")
+	}
+	instanceA.Publish("prices", price{"A", 4})
+	c.ExpectNoPush(50 * time.Millisecond)
+
+	c.Call("prices.subscribe", "A", &status)
+	sub = <-subs
+	c.Close()
+	select {
+	case <-sub.Done():
+	case <-time.After(wstest.Timeout):
+		t.Errorf("subscription not canceled when connection closed")
+	}
+}
+
+func TestLateSubscription(t *testing.T) {
+	errs := make(chan error, 8)
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) { errs <- err })
+
+	subs := make(chan *ws.Subscription, 1)
+	w.RegisterHandler("prices.subscribe", func(r *ws.Request) {
+		r.Respond("subscribed")
+		// subscribes after connection is closed
+		go func() {
+			<-r.C.Context().Done()
+			subs <- r.Subscribe("prices", nil)
+		}()
+	})
+
+	c := wstest.Connect(t, w)
+	c.Call("prices.subscribe", nil, nil)
+	c.Close()
+
+	sub := <-subs
+	select {
+	case <-sub.Done():
+	default:
+		t.Errorf("subscription of closed connection not canceled")
+	}
+	// close of the connection may be reported
+	for len(errs) > 0 {
+		<-errs
+	}
+	if err := w.Publish("prices", price{"A", 1}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		t.Errorf("publish to closed connection reported %v", err)
+	default:
+	}
+}
+
+func TestJSONRPCSubscriptions(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	w.RegisterHandler("subscribe", func(r *ws.Request) {
+		r.Subscribe("prices", nil)
+		r.Respond(len(r.C.Subscriptions()))
+	})
+	w.RegisterHandler("unsubscribe", func(r *ws.Request, id json.RawMessage) {
+		r.Respond(r.C.UnsubscribeID(id))
+	})
+
+	conn, _, err := memws.NewDialer(w.Handler(ws.JSONRPC)).Dial("ws://example.org/websocket", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	call := func(req string) string {
+		t.Helper()
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(req)); err != nil {
+			t.Fatal(err)
+		}
+		_, resp, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(resp)
+	}
+
+	// string IDs are distinct subscriptions, notifications are never replaced
+	for _, test := range []struct{ Request, Response string }{
+		{`{"jsonrpc":"2.0","method":"subscribe","id":"x"}`, `{"jsonrpc":"2.0","id":"x","result":1}`},
+		{`{"jsonrpc":"2.0","method":"subscribe","id":"y"}`, `{"jsonrpc":"2.0","id":"y","result":2}`},
+		{`{"jsonrpc":"2.0","method":"subscribe","id":"y"}`, `{"jsonrpc":"2.0","id":"y","result":2}`},
+		{`{"jsonrpc":"2.0","method":"unsubscribe","params":"x","id":1}`, `{"jsonrpc":"2.0","id":1,"result":true}`},
+		{`{"jsonrpc":"2.0","method":"unsubscribe","params":"x","id":2}`, `{"jsonrpc":"2.0","id":2,"result":false}`},
+	} {
+		if got := call(test.Request); got != test.Response {
+			t.Errorf("%s: got %s; want %s", test.Request, got, test.Response)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"subscribe"}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := call(`{"jsonrpc":"2.0","method":"subscribe","id":"z"}`), `{"jsonrpc":"2.0","id":"z","result":4}`; got != want {
+		t.Errorf("subscribe after notifications: got %s; want %s", got, want)
+	}
+}
@@ -0,0 +1,88 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+)
+
+// Topic describes topic with request payload Req and response data Resp.
+// Declared once in package shared by server and client, it registers the handler,
+// makes calls and sends pushes, so names and payload types are checked at compile time:
+//
+//	var Join = ws.NewTopic[JoinRequest, Room]("chat.join")
+//
+//	Join.Handle(w, func(r *ws.Request, req JoinRequest) (Room, error) { ... })
+//	room, err := Join.Call(ctx, client, JoinRequest{Name: "lobby"})
+type Topic[Req, Resp any] struct {
+	name string
+}
+
+// NewTopic returns descriptor of the topic
+func NewTopic[Req, Resp any](name string) Topic[Req, Resp] {
+	return Topic[Req, Resp]{name: name}
+}
+
+// Name returns name of the topic
+func (t Topic[Req, Resp]) Name() string {
+	return t.name
+}
+
+// topicRouter registers handlers under names not prefixed by group, implemented by WS and groups
+type topicRouter interface {
+	handle(topic string, handlerFn interface{}, replace bool)
+	setOutput(topic string, out reflect.Type)
+}
+
+// Handle registers fn as handler of the topic, response is sent if fn returns no error.
+// The handler is registered under name of the topic even if r is a Group,
+// so it matches Call and Push, middleware of the group is applied.
+func (t Topic[Req, Resp]) Handle(r Router, fn func(r *Request, req Req) (Resp, error)) {
+	handlerFn := func(r *Request, req Req) error {
+		resp, err := fn(r, req)
+		if err != nil {
+			return err
+		}
+		return r.Respond(resp)
+	}
+
+	tr, ok := r.(topicRouter)
+	if !ok {
+		r.RegisterHandler(t.name, handlerFn)
+		return
+	}
+	tr.handle(t.name, handlerFn, false)
+	tr.setOutput(t.name, reflect.TypeOf((*Resp)(nil)).Elem())
+}
+
+// Caller sends request and decodes response, implemented by client.Client
+type Caller interface {
+	Call(ctx context.Context, topic string, payload interface{}, out interface{}) error
+}
+
+// Call sends request of the topic with c and returns its response
+func (t Topic[Req, Resp]) Call(ctx context.Context, c Caller, req Req) (Resp, error) {
+	var resp Resp
+	err := c.Call(ctx, t.name, req, &resp)
+	return resp, err
+}
+
+// Push sends data to the connection as push of the topic
+func (t Topic[Req, Resp]) Push(conn *Connection, data Resp) error {
+	return conn.Send(0, t.name, data)
+}
+
+// Broadcast sends data as push of the topic to every connection which joined the room
+func (t Topic[Req, Resp]) Broadcast(w WS, room string, data Resp) error {
+	return w.Broadcast(room, t.name, data)
+}
+
+// Decode returns data of push received by client and reports whether it is push of the topic
+func (t Topic[Req, Resp]) Decode(topic string, data json.RawMessage) (Resp, bool, error) {
+	var resp Resp
+	if topic != t.name {
+		return resp, false, nil
+	}
+	err := json.Unmarshal(data, &resp)
+	return resp, true, err
+}
@@ -0,0 +1,82 @@
+package ws_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/wstest"
+)
+
+type joinRequest struct {
+	Room string
+}
+
+type joined struct {
+	Room    string
+	Members int
+}
+
+var joinTopic = ws.NewTopic[joinRequest, joined]("chat.join")
+
+func TestTopic(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	joinTopic.Handle(w, func(r *ws.Request, req joinRequest) (joined, error) {
+		if req.Room == "" {
+			return joined{}, &ws.Error{Code: ws.CodeInvalidPayload, Message: "room required"}
+		}
+		r.C.Join(req.Room)
+		go joinTopic.Push(r.C, joined{Room: req.Room, Members: 2})
+		return joined{Room: req.Room, Members: 1}, nil
+	})
+
+	c := wstest.Connect(t, w)
+	ctx, cancel := context.WithTimeout(context.Background(), wstest.Timeout)
+	defer cancel()
+
+	resp, err := joinTopic.Call(ctx, c.Client, joinRequest{Room: "lobby"})
+	if err != nil || resp != (joined{"lobby", 1}) {
+		t.Errorf("got %+v %v; want lobby 1", resp, err)
+	}
+
+	msg := c.Next()
+	push, ok, err := joinTopic.Decode(msg.Topic, msg.Data)
+	if !ok || err != nil || push != (joined{"lobby", 2}) {
+		t.Errorf("got push %+v %v %v; want lobby 2", push, ok, err)
+	}
+
+	var wsErr *ws.Error
+	if _, err := joinTopic.Call(ctx, c.Client, joinRequest{}); !errors.As(err, &wsErr) || wsErr.Message != "room required" {
+		t.Errorf("got %v; want room required", err)
+	}
+}
+
+func TestTopicInGroup(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	var groupMiddleware bool
+	chat := w.Group("chat.", func(next ws.HandlerFunc) ws.HandlerFunc {
+		return func(r *ws.Request) error {
+			groupMiddleware = true
+			return next(r)
+		}
+	})
+	joinTopic.Handle(chat, func(r *ws.Request, req joinRequest) (joined, error) {
+		return joined{Room: req.Room, Members: 1}, nil
+	})
+
+	if handlers := w.Handlers(); len(handlers) != 1 || handlers[0].Topic != "chat.join" {
+		t.Errorf("got handlers %+v; want chat.join", handlers)
+	}
+
+	c := wstest.Connect(t, w)
+	ctx, cancel := context.WithTimeout(context.Background(), wstest.Timeout)
+	defer cancel()
+
+	if resp, err := joinTopic.Call(ctx, c.Client, joinRequest{Room: "lobby"}); err != nil || resp != (joined{"lobby", 1}) {
+		t.Errorf("got %+v %v; want lobby 1", resp, err)
+	}
+	if !groupMiddleware {
+		t.Errorf("middleware of the group was not applied")
+	}
+}
@@ -0,0 +1,301 @@
+package ws
+
+import (
+	"errors"
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Traffic counts frames and their bytes in each direction
+type Traffic struct {
+	BytesIn     int64 `json:"bytes_in"`
+	BytesOut    int64 `json:"bytes_out"`
+	MessagesIn  int64 `json:"messages_in"`
+	MessagesOut int64 `json:"messages_out"`
+}
+
+type trafficCounter struct {
+	bytesIn, bytesOut, messagesIn, messagesOut int64
+
+	// conns and idle track connections of principal, guarded by ws.tl
+	conns int
+	idle  time.Time
+}
+
+func (c *trafficCounter) add(d Direction, n int) {
+	if d == Inbound {
+		atomic.AddInt64(&c.bytesIn, int64(n))
+		atomic.AddInt64(&c.messagesIn, 1)
+		return
+	}
+	atomic.AddInt64(&c.bytesOut, int64(n))
+	atomic.AddInt64(&c.messagesOut, 1)
+}
+
+func (c *trafficCounter) load() Traffic {
+	return Traffic{
+		BytesIn:     atomic.LoadInt64(&c.bytesIn),
+		BytesOut:    atomic.LoadInt64(&c.bytesOut),
+		MessagesIn:  atomic.LoadInt64(&c.messagesIn),
+		MessagesOut: atomic.LoadInt64(&c.messagesOut),
+	}
+}
+
+var trafficStats = expvar.NewMap("websocket_traffic")
+
+// Traffic returns frames received and sent by the connection
+func (conn *Connection) Traffic() Traffic {
+	return conn.traffic.load()
+}
+
+// TrafficOptions configures counters of principals
+type TrafficOptions struct {
+	// Retention is how long counters of principal are kept after its last connection is closed,
+	// 1 hour by default, they are removed at most twice as late
+	Retention time.Duration
+	// Clock measures retention, SystemClock by default
+	Clock Clock
+}
+
+// WithTraffic configures counters of principals
+func WithTraffic(opts TrafficOptions) Option {
+	return func(m *ws) {
+		m.trafficOpts = opts
+	}
+}
+
+func (o TrafficOptions) withDefaults() TrafficOptions {
+	if o.Retention == 0 {
+		o.Retention = time.Hour
+	}
+	if o.Clock == nil {
+		o.Clock = SystemClock
+	}
+	return o
+}
+
+// Traffic returns frames received and sent by connections of the principal served by this instance,
+// counters of principals are kept for TrafficOptions.Retention after they disconnect
+func (m *ws) Traffic(principal string) Traffic {
+	m.tl.Lock()
+	c, ok := m.principalTraffic[principal]
+	m.tl.Unlock()
+	if !ok {
+		return Traffic{}
+	}
+	return c.load()
+}
+
+// ErrQuotaExceeded is returned by sends dropped because quota was exceeded
+var ErrQuotaExceeded = errors.New("ws: quota exceeded")
+
+// Quota limits traffic within period
+type Quota struct {
+	// Per is the period, e.g. time.Minute or 24*time.Hour, periods are aligned to multiples of Per since zero time (UTC midnight for days)
+	Per time.Duration
+	// Bytes limits bytes of frames within the period, zero means no limit
+	Bytes int64
+	// Messages limits number of frames within the period, zero means no limit
+	Messages int64
+	// Direction limits counted frames to inbound or outbound, both are counted if empty
+	Direction Direction
+	// Principal shares quota by all connections of the principal, connections without principal have own quota
+	Principal bool
+}
+
+// QuotaOptions configures quotas
+type QuotaOptions struct {
+	Quotas []Quota
+	// OnExceeded is called once per period when quota is exceeded, frames keep flowing.
+	// Connection is closed with 1008 (policy violation) and frames are dropped if it is nil.
+	OnExceeded func(conn *Connection, q Quota)
+	// Clock measures periods, SystemClock by default
+	Clock Clock
+}
+
+// WithQuotas limits traffic of connections, it panics if period of quota is not positive
+func WithQuotas(opts QuotaOptions) Option {
+	return func(m *ws) {
+		if opts.Clock == nil {
+			opts.Clock = SystemClock
+		}
+		var shortest time.Duration
+		for _, q := range opts.Quotas {
+			if q.Per <= 0 {
+				panic("ws: quota period must be positive")
+			}
+			if shortest == 0 || q.Per < shortest {
+				shortest = q.Per
+			}
+		}
+		m.quotas = &quotas{opts: opts, shortest: shortest, principals: make(map[string][]quotaWindow)}
+	}
+}
+
+type quotas struct {
+	opts     QuotaOptions
+	shortest time.Duration
+
+	mu         sync.Mutex
+	principals map[string][]quotaWindow
+	swept      time.Time
+}
+
+// quotaWindow is usage of quota in period starting at start
+type quotaWindow struct {
+	start    time.Time
+	bytes    int64
+	messages int64
+	exceeded bool
+}
+
+// account counts frame of the connection and reports whether it is within quotas
+func (m *ws) account(conn *Connection, d Direction, n int) bool {
+	conn.traffic.add(d, n)
+	if d == Inbound {
+		trafficStats.Add("bytes_in", int64(n))
+		trafficStats.Add("messages_in", 1)
+	} else {
+		trafficStats.Add("bytes_out", int64(n))
+		trafficStats.Add("messages_out", 1)
+	}
+
+	if conn.principal != "" {
+		m.tl.Lock()
+		c := m.principalCounter(conn.principal)
+		m.tl.Unlock()
+		c.add(d, n)
+	}
+
+	if m.quotas == nil {
+		return true
+	}
+	exceeded := m.quotas.check(conn, d, n)
+	if len(exceeded) == 0 {
+		return true
+	}
+	if m.quotas.opts.OnExceeded == nil {
+		conn.Close(websocket.ClosePolicyViolation, "quota exceeded")
+		return false
+	}
+	for _, q := range exceeded {
+		m.quotas.opts.OnExceeded(conn, q)
+	}
+	return true
+}
+
+// check counts frame in windows of quotas and returns quotas exceeded by it,
+// without OnExceeded every frame over quota is reported
+func (q *quotas) check(conn *Connection, d Direction, n int) []Quota {
+	now := q.opts.Clock.Now()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.sweep(now)
+	if conn.quotaWindows == nil {
+		conn.quotaWindows = make([]quotaWindow, len(q.opts.Quotas))
+	}
+	principal := q.principals[conn.principal]
+	if conn.principal != "" && principal == nil {
+		principal = make([]quotaWindow, len(q.opts.Quotas))
+		q.principals[conn.principal] = principal
+	}
+
+	var exceeded []Quota
+	for i, quota := range q.opts.Quotas {
+		if quota.Direction != "" && quota.Direction != d {
+			continue
+		}
+
+		w := &conn.quotaWindows[i]
+		if quota.Principal && conn.principal != "" {
+			w = &principal[i]
+		}
+		if start := now.Truncate(quota.Per); !w.start.Equal(start) {
+			*w = quotaWindow{start: start}
+		}
+		w.bytes += int64(n)
+		w.messages++
+
+		over := quota.Bytes > 0 && w.bytes > quota.Bytes || quota.Messages > 0 && w.messages > quota.Messages
+		if over && (!w.exceeded || q.opts.OnExceeded == nil) {
+			exceeded = append(exceeded, quota)
+		}
+		w.exceeded = w.exceeded || over
+	}
+	return exceeded
+}
+
+// sweep removes windows of principals whose periods have all passed,
+// they would be started again by the next frame, must be called with q.mu held
+func (q *quotas) sweep(now time.Time) {
+	if now.Sub(q.swept) < q.shortest {
+		return
+	}
+	q.swept = now
+
+	for principal, windows := range q.principals {
+		expired := true
+		for i, w := range windows {
+			if now.Before(w.start.Add(q.opts.Quotas[i].Per)) {
+				expired = false
+				break
+			}
+		}
+		if expired {
+			delete(q.principals, principal)
+		}
+	}
+}
+
+// principalCounter returns counter of the principal, must be called with m.tl held
+func (m *ws) principalCounter(principal string) *trafficCounter {
+	c, ok := m.principalTraffic[principal]
+	if !ok {
+		c = &trafficCounter{}
+		m.principalTraffic[principal] = c
+	}
+	return c
+}
+
+// connectPrincipal keeps counter of principal of the connection while it is connected
+func (m *ws) connectPrincipal(conn *Connection) {
+	if conn.principal == "" {
+		return
+	}
+	m.tl.Lock()
+	defer m.tl.Unlock()
+	m.principalCounter(conn.principal).conns++
+}
+
+// disconnectPrincipal starts retention of counter of principal without connections
+// and removes counters retained for longer
+func (m *ws) disconnectPrincipal(conn *Connection) {
+	if conn.principal == "" {
+		return
+	}
+	now := m.trafficOpts.Clock.Now()
+
+	m.tl.Lock()
+	defer m.tl.Unlock()
+
+	c := m.principalCounter(conn.principal)
+	if c.conns--; c.conns == 0 {
+		c.idle = now
+	}
+
+	if now.Sub(m.trafficSwept) < m.trafficOpts.Retention {
+		return
+	}
+	m.trafficSwept = now
+	for principal, c := range m.principalTraffic {
+		if c.conns == 0 && now.Sub(c.idle) >= m.trafficOpts.Retention {
+			delete(m.principalTraffic, principal)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package ws_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/wstest"
+	"github.com/gorilla/websocket"
+)
+
+func TestTraffic(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {},
+		ws.WithPrincipal(func(r *http.Request) string { return r.Header.Get("User") }))
+
+	conns := make(chan *ws.Connection, 2)
+	w.RegisterHandler("echo", func(r *ws.Request, s string) {
+		r.Respond(s)
+		conns <- r.C
+	})
+
+	alice := http.Header{"User": {"alice"}}
+	for i := 0; i < 2; i++ {
+		c := wstest.Connect(t, w, wstest.WithHeader(alice))
+		var got string
+		c.Call("echo", "hello", &got)
+	}
+
+	for i := 0; i < 2; i++ {
+		traffic := (<-conns).Traffic()
+		if traffic.MessagesIn != 1 || traffic.MessagesOut != 1 || traffic.BytesIn == 0 || traffic.BytesOut == 0 {
+			t.Errorf("connection traffic %+v; want single message in each direction", traffic)
+		}
+	}
+	if traffic := w.Traffic("alice"); traffic.MessagesIn != 2 || traffic.MessagesOut != 2 {
+		t.Errorf("principal traffic %+v; want two messages in each direction", traffic)
+	}
+}
+
+func TestQuotas(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {},
+		ws.WithQuotas(ws.QuotaOptions{Quotas: []ws.Quota{{Per: time.Minute, Messages: 2, Direction: ws.Inbound}}}))
+	w.RegisterHandler("echo", func(r *ws.Request, s string) { r.Respond(s) })
+
+	c := wstest.Connect(t, w)
+	c.Call("echo", "1", nil)
+	c.Call("echo", "2", nil)
+	c.Send("echo", "3")
+	c.ExpectClose(websocket.ClosePolicyViolation)
+}
+
+func TestQuotasPerPrincipal(t *testing.T) {
+	clock := wstest.NewClock(time.Unix(0, 0))
+	exceeded := make(chan string, 10)
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {},
+		ws.WithPrincipal(func(r *http.Request) string { return r.Header.Get("User") }),
+		ws.WithQuotas(ws.QuotaOptions{
+			Quotas: []ws.Quota{{Per: time.Minute, Messages: 3, Principal: true}},
+			OnExceeded: func(conn *ws.Connection, q ws.Quota) {
+				exceeded <- conn.Principal()
+			},
+			Clock: clock,
+		}))
+	w.RegisterHandler("echo", func(r *ws.Request, s string) { r.Respond(s) })
+
+	alice := wstest.WithHeader(http.Header{"User": {"alice"}})
+	a, b := wstest.Connect(t, w, alice), wstest.Connect(t, w, alice)
+
+	// two frames per call, quota is exceeded by the first frame of the second call and reported once
+	a.Call("echo", "1", nil)
+	b.Call("echo", "2", nil)
+	a.Call("echo", "3", nil)
+	if len(exceeded) != 1 || <-exceeded != "alice" {
+		t.Errorf("got %d exceeded callbacks; want 1 for alice", len(exceeded))
+	}
+
+	clock.Advance(time.Minute)
+	a.Call("echo", "4", nil)
+	if len(exceeded) != 0 {
+		t.Errorf("quota exceeded in new period")
+	}
+}
+
+func TestTrafficRetention(t *testing.T) {
+	clock := wstest.NewClock(time.Unix(0, 0))
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {},
+		ws.WithPrincipal(func(r *http.Request) string { return r.Header.Get("User") }),
+		ws.WithTraffic(ws.TrafficOptions{Retention: time.Hour, Clock: clock}))
+	ids := make(chan string, 1)
+	w.RegisterHandler("echo", func(r *ws.Request, s string) {
+		ids <- r.C.ID()
+		r.Respond(s)
+	})
+
+	// session returns after connection of the user is unregistered
+	session := func(user string) {
+		c := wstest.Connect(t, w, wstest.WithHeader(http.Header{"User": {user}}))
+		c.Call("echo", "hello", nil)
+		id := <-ids
+		c.Close()
+
+		deadline := time.Now().Add(wstest.Timeout)
+		for w.SendTo(id, "ping", nil) != ws.ErrUnknownConnection {
+			if time.Now().After(deadline) {
+				t.Fatalf("connection of %s not unregistered", user)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	session("alice")
+	clock.Advance(time.Hour)
+	// counters are removed when other connection is closed
+	session("bob")
+
+	if traffic := w.Traffic("alice"); traffic != (ws.Traffic{}) {
+		t.Errorf("traffic of alice %+v kept after retention", traffic)
+	}
+	if traffic := w.Traffic("bob"); traffic.MessagesIn != 1 {
+		t.Errorf("traffic of bob %+v; want single message", traffic)
+	}
+}
+
+func TestQuotaPeriodRequired(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("quota without period accepted")
+		}
+	}()
+	ws.NewWS(nil, func(connection *ws.Connection, err error) {},
+		ws.WithQuotas(ws.QuotaOptions{Quotas: []ws.Quota{{Messages: 1}}}))
+}
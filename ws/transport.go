@@ -0,0 +1,71 @@
+package ws
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// transport delivers frames to single client
+type transport interface {
+	// write sends text frame, topic is used only for compression decisions
+	write(topic string, frame []byte) error
+
+	// writeBinary sends binary frame, ErrBinaryUnsupported if transport does not support it
+	writeBinary(frame []byte) error
+
+	// close terminates connection with the close code and reason
+	close(code int, reason string) error
+}
+
+// wsTransport sends frames over websocket connection
+type wsTransport struct {
+	l    sync.Mutex
+	conn *websocket.Conn
+
+	compression *compression
+	compressed  bool
+	wire        *countingConn
+}
+
+func (t *wsTransport) write(topic string, b []byte) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	c := t.compression
+	if c == nil || !t.compressed {
+		return t.conn.WriteMessage(websocket.TextMessage, b)
+	}
+
+	compress := c.shouldCompress(topic, len(b))
+	t.conn.EnableWriteCompression(compress)
+	if !compress {
+		compressionStats.Add("frames_uncompressed", 1)
+		return t.conn.WriteMessage(websocket.TextMessage, b)
+	}
+
+	compressionStats.Add("frames_compressed", 1)
+	compressionStats.Add("payload_bytes", int64(len(b)))
+	before := t.wire.Written()
+	err := t.conn.WriteMessage(websocket.TextMessage, b)
+	compressionStats.Add("wire_bytes", t.wire.Written()-before)
+	return err
+}
+
+func (t *wsTransport) writeBinary(b []byte) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	if t.compression != nil && t.compressed {
+		t.conn.EnableWriteCompression(false)
+	}
+	return t.conn.WriteMessage(websocket.BinaryMessage, b)
+}
+
+func (t *wsTransport) close(code int, reason string) error {
+	msg := websocket.FormatCloseMessage(code, reason)
+	err := t.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	t.conn.Close()
+	return err
+}
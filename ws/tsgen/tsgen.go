@@ -0,0 +1,360 @@
+// Package tsgen generates TypeScript client of handlers registered on ws.WS.
+//
+// Generator is run by small program building the WS with its handlers,
+// usually invoked by go generate:
+//
+//	//go:generate go run ./cmd/gents -o web/src/api.ts
+//
+//	func main() {
+//		tsgen.Command(api.NewWS())
+//	}
+//
+// The module contains interfaces of payload and response types, Topics map describing
+// every topic and Client class with method for every topic, calling topics through Transport.
+// WSTransport implements Transport over browser WebSocket using the Native protocol.
+// Response types are known only for handlers registered with ws.Topic, others respond with unknown.
+package tsgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/IAmRadek/go-kit/ws"
+)
+
+// Options configures generated module
+type Options struct {
+	// ClientName is name of generated client class, "Client" by default
+	ClientName string
+}
+
+// Command writes module generated from handlers of w to file passed with -o flag or to stdout
+func Command(w interface{ Handlers() []ws.HandlerInfo }) {
+	var (
+		out    = flag.String("o", "", "output file, stdout if empty")
+		client = flag.String("client", "Client", "name of generated client class")
+	)
+	flag.Parse()
+
+	var buf bytes.Buffer
+	if err := Generate(&buf, w.Handlers(), Options{ClientName: *client}); err != nil {
+		log.Fatalf("tsgen: %v", err)
+	}
+	if *out == "" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	if err := os.WriteFile(*out, buf.Bytes(), 0o644); err != nil {
+		log.Fatalf("tsgen: %v", err)
+	}
+}
+
+// Generate writes TypeScript module describing handlers
+func Generate(w io.Writer, handlers []ws.HandlerInfo, opts Options) error {
+	if opts.ClientName == "" {
+		opts.ClientName = "Client"
+	}
+
+	g := &generator{names: make(map[reflect.Type]string), taken: make(map[string]reflect.Type)}
+
+	type topic struct {
+		ws.HandlerInfo
+		in, out string
+	}
+	topics := make([]topic, 0, len(handlers))
+	for _, h := range handlers {
+		t := topic{HandlerInfo: h, in: "void", out: "unknown"}
+		if h.In != nil {
+			t.in = g.typeOf(h.In)
+		}
+		if h.Out != nil {
+			t.out = g.typeOf(h.Out)
+		}
+		topics = append(topics, t)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by tsgen. DO NOT EDIT.\n\n")
+	for _, decl := range g.decls {
+		buf.WriteString(decl)
+		buf.WriteString("\n")
+	}
+
+	buf.WriteString("export interface Topics {\n")
+	for _, t := range topics {
+		fmt.Fprintf(&buf, "  %q: { request: %s; response: %s };\n", t.Topic, t.in, t.out)
+	}
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(transport)
+
+	fmt.Fprintf(&buf, "export class %s {\n", opts.ClientName)
+	buf.WriteString("  constructor(readonly transport: Transport) {}\n")
+	methods := make(map[string]int)
+	for _, t := range topics {
+		name, params, topicExpr := method(t.Topic)
+		if methods[name]++; methods[name] > 1 {
+			name = fmt.Sprintf("%s%d", name, methods[name])
+		}
+		if t.In != nil {
+			params = append(params, "data: "+t.in)
+		}
+		data := "undefined"
+		if t.In != nil {
+			data = "data"
+		}
+		fmt.Fprintf(&buf, "\n  %s(%s): Promise<%s> {\n", name, strings.Join(params, ", "), t.out)
+		fmt.Fprintf(&buf, "    return this.transport.call(%s, %s) as Promise<%s>;\n  }\n", topicExpr, data, t.out)
+	}
+	buf.WriteString("}\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// method returns client method name, parameters and topic expression of topic pattern
+func method(topic string) (string, []string, string) {
+	var name strings.Builder
+	var params []string
+	var parts []string
+	literal := true
+
+	for _, segment := range strings.Split(topic, ".") {
+		switch {
+		case segment == "*":
+			params = append(params, "rest: string")
+			parts = append(parts, "${rest}")
+			literal = false
+		case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"):
+			param := identifier(segment[1 : len(segment)-1])
+			params = append(params, param+": string")
+			parts = append(parts, "${"+param+"}")
+			literal = false
+		default:
+			parts = append(parts, segment)
+			for _, word := range strings.FieldsFunc(segment, func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			}) {
+				if name.Len() == 0 {
+					name.WriteString(strings.ToLower(word[:1]) + word[1:])
+					continue
+				}
+				name.WriteString(upperFirst(word))
+			}
+		}
+	}
+
+	n := name.String()
+	if n == "" || unicode.IsDigit(rune(n[0])) {
+		n = "call" + upperFirst(n)
+	}
+	if literal {
+		return n, params, fmt.Sprintf("%q", topic)
+	}
+	return n, params, "`" + strings.Join(parts, ".") + "`"
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// identifier returns valid TypeScript identifier
+func identifier(s string) string {
+	id := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+	if id == "" || unicode.IsDigit(rune(id[0])) {
+		id = "_" + id
+	}
+	return id
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	marshalerType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+type generator struct {
+	names map[reflect.Type]string
+	taken map[string]reflect.Type
+	decls []string
+}
+
+// typeOf returns TypeScript type of values of t encoded by encoding/json
+func (g *generator) typeOf(t reflect.Type) string {
+	switch {
+	case t == timeType:
+		return "string"
+	case t == rawMessageType:
+		return "unknown"
+	case t.Kind() != reflect.Pointer && t.Implements(marshalerType),
+		t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(marshalerType):
+		return "unknown"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Pointer:
+		return g.typeOf(t.Elem()) + " | null"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			// base64 encoded
+			return "string"
+		}
+		return wrap(g.typeOf(t.Elem())) + "[]"
+	case reflect.Map:
+		return "Record<string, " + g.typeOf(t.Elem()) + ">"
+	case reflect.Struct:
+		return g.named(t)
+	default:
+		return "unknown"
+	}
+}
+
+// wrap parenthesizes union types used as array elements
+func wrap(ts string) string {
+	if strings.Contains(ts, "|") {
+		return "(" + ts + ")"
+	}
+	return ts
+}
+
+// named declares interface of struct type and returns its name
+func (g *generator) named(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := identifier(upperFirst(t.Name()))
+	if t.Name() == "" {
+		name = "Anonymous"
+	}
+	for i := 2; ; i++ {
+		if other, ok := g.taken[name]; !ok || other == t {
+			break
+		}
+		name = fmt.Sprintf("%s%d", strings.TrimRight(name, "0123456789"), i)
+	}
+	g.names[t] = name
+	g.taken[name] = t
+
+	var fields strings.Builder
+	g.fields(&fields, t)
+	g.decls = append(g.decls, fmt.Sprintf("export interface %s {\n%s}\n", name, fields.String()))
+	return name
+}
+
+// fields writes fields of struct t as encoded by encoding/json, including embedded structs
+func (g *generator) fields(w *strings.Builder, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(w, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		optional := ""
+		if strings.Contains(opts, "omitempty") {
+			optional = "?"
+		}
+		typ := g.typeOf(ft)
+		if strings.Contains(opts, "string") {
+			typ = "string"
+		}
+		fmt.Fprintf(w, "  %s%s: %s;\n", property(name), optional, typ)
+	}
+}
+
+// property quotes name unless it is valid identifier
+func property(name string) string {
+	if identifier(name) == name {
+		return name
+	}
+	return fmt.Sprintf("%q", name)
+}
+
+const transport = `export interface Transport {
+  call(topic: string, data: unknown): Promise<unknown>;
+}
+
+export interface WSError {
+  Code: string;
+  Message: string;
+  Details?: unknown;
+}
+
+// WSTransport calls topics over WebSocket framing requests as {ID, Topic, Data}
+export class WSTransport implements Transport {
+  private nextID = 0;
+  private pending = new Map<number, { resolve: (data: unknown) => void; reject: (err: WSError) => void }>();
+
+  constructor(readonly socket: WebSocket, readonly onPush?: (topic: string, data: unknown) => void) {
+    socket.addEventListener("message", (event) => {
+      const frame = JSON.parse(event.data as string);
+      for (const msg of Array.isArray(frame) ? frame : [frame]) {
+        const call = this.pending.get(msg.ID);
+        if (!msg.ID || !call) {
+          this.onPush?.(msg.Topic, msg.Data);
+          continue;
+        }
+        this.pending.delete(msg.ID);
+        if (msg.Error) {
+          call.reject(msg.Error);
+        } else {
+          call.resolve(msg.Data);
+        }
+      }
+    });
+  }
+
+  call(topic: string, data: unknown): Promise<unknown> {
+    const ID = ++this.nextID;
+    return new Promise((resolve, reject) => {
+      this.pending.set(ID, { resolve, reject });
+      this.socket.send(JSON.stringify({ ID, Topic: topic, Data: data }));
+    });
+  }
+}
+
+`
@@ -0,0 +1,67 @@
+package tsgen_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/tsgen"
+)
+
+type Base struct {
+	ID string `json:"id"`
+}
+
+type Message struct {
+	Base
+	Text    string         `json:"text"`
+	Author  *User          `json:"author,omitempty"`
+	Tags    []string       `json:"tags"`
+	Meta    map[string]int `json:"meta"`
+	Sent    time.Time      `json:"sent"`
+	secret  string
+	Skipped string `json:"-"`
+}
+
+type User struct {
+	Name string
+}
+
+var send = ws.NewTopic[Message, Message]("chat.send")
+
+func TestGenerate(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	send.Handle(w, func(r *ws.Request, m Message) (Message, error) {
+		return m, nil
+	})
+	w.RegisterHandler("room.{id}.leave", func(r *ws.Request) {})
+	w.RegisterHandler("user.rename", func(r *ws.Request, name string) {})
+
+	handlers := w.Handlers()
+	if len(handlers) != 3 || handlers[0].Topic != "chat.send" || handlers[0].Out == nil || handlers[2].Out != nil {
+		t.Fatalf("handlers %+v; want chat.send with output and room, user without", handlers)
+	}
+
+	var buf bytes.Buffer
+	if err := tsgen.Generate(&buf, handlers, tsgen.Options{ClientName: "ChatClient"}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"export interface Message {\n  id: string;\n  text: string;\n  author?: User | null;\n  tags: string[];\n  meta: Record<string, number>;\n  sent: string;\n}\n",
+		"export interface User {\n  Name: string;\n}\n",
+		`"chat.send": { request: Message; response: Message };`,
+		`"user.rename": { request: string; response: unknown };`,
+		"export class ChatClient {",
+		"  chatSend(data: Message): Promise<Message> {\n    return this.transport.call(\"chat.send\", data) as Promise<Message>;",
+		"  roomLeave(id: string): Promise<unknown> {\n    return this.transport.call(`room.${id}.leave`, undefined) as Promise<unknown>;",
+		"  userRename(data: string): Promise<unknown> {",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain:\n%s\n\noutput:\n%s", want, out)
+		}
+	}
+}
@@ -8,14 +8,20 @@ import (
 	"net/http"
 	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/IAmRadek/go-kit/random"
 	"github.com/gorilla/websocket"
 )
 
 type WS interface {
-	// RegisterHandler register function for entered topic
-	// note that handlerFn is interface{} but should be function (func(r *Request, optionalParameter string))
-	RegisterHandler(topic string, handlerFn interface{})
+	Router
+
+	// SetFallback registers function called for topics without handler,
+	// handlerFn has the same form as in RegisterHandler,
+	// it is safe to call while connections are served
+	SetFallback(handlerFn interface{})
 
 	// AddPreHook appends pre main loop hook
 	AddPreHook(hook Hook)
@@ -23,49 +29,264 @@ type WS interface {
 	// AddPostHook appends post request hook
 	AddPostHook(hook Hook)
 
+	// Traffic returns frames received and sent by connections of the principal
+	Traffic(principal string) Traffic
+
+	// Handlers returns handlers registered for topics sorted by topic, without fallback
+	Handlers() []HandlerInfo
+
+	// AddLifecycle appends lifecycle receiving connect, message, error and close events
+	// of every connection, OnConnect is called before pre hooks and may reject the connection
+	AddLifecycle(l Lifecycle)
+
+	// Broadcast sends data to every connection which joined the room,
+	// or to every connection if room is empty, including connections
+	// served by other instances sharing the Broker
+	Broadcast(room string, topic string, data interface{}) error
+
+	// Publish delivers event to subscriptions of the channel, see Request.Subscribe,
+	// including subscriptions of connections served by other instances sharing the Broker
+	Publish(channel string, event interface{}) error
+
+	// SendTo sends data to the connection with entered id,
+	// wherever it is served when a Broker is configured
+	SendTo(connID string, topic string, data interface{}) error
+
+	// Handler returns websocket endpoint using the protocol,
+	// all endpoints share registered handlers and hooks
+	Handler(p Protocol) http.Handler
+
+	// HTTPFallback returns handler serving the protocol over plain HTTP
+	// using Server-Sent Events or long-polling
+	HTTPFallback(p Protocol) http.Handler
+
+	// HTTPBridge returns handler invoking registered topics with POST .../topic/{name}
+	HTTPBridge() http.Handler
+
+	// Admin returns handler listing connections and topic stats, closing connections
+	// and broadcasting messages, for requests accepted by authorize
+	Admin(authorize func(r *http.Request) bool) http.Handler
+
+	// ServeHTTP serves websocket endpoint using Native protocol
 	ServeHTTP(w http.ResponseWriter, r *http.Request)
 }
 
-func NewWS(upgrader *websocket.Upgrader, errorHandler func(*Connection, error)) WS {
+// Option configures optional behaviour of WS
+type Option func(m *ws)
+
+// WithBroker propagates broadcasts and targeted sends through the broker,
+// so they reach connections served by other instances
+func WithBroker(broker Broker) Option {
+	return func(m *ws) {
+		m.broker = broker
+	}
+}
+
+// WithPrincipal resolves principal (e.g. user id) of every connection from its request
+func WithPrincipal(fn func(r *http.Request) string) Option {
+	return func(m *ws) {
+		m.principal = fn
+	}
+}
+
+func NewWS(upgrader *websocket.Upgrader, errorHandler func(*Connection, error), opts ...Option) WS {
 	if upgrader == nil {
 		upgrader = &websocket.Upgrader{}
 	}
-	return &ws{
+	m := &ws{
 		ErrorHandler: errorHandler,
 		Upgrader:     upgrader,
+
+		id:       random.Hex(16),
+		conns:    make(map[string]*Connection),
+		rooms:    make(map[string]map[*Connection]struct{}),
+		channels: make(map[string]map[*Subscription]struct{}),
+		sessions: make(map[string]*session),
+
+		principalTraffic: make(map[string]*trafficCounter),
 	}
+	m.root = &group{ws: m}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.trafficOpts = m.trafficOpts.withDefaults()
+	if m.broker != nil {
+		if _, err := m.broker.Subscribe(m.receive); err != nil {
+			panic("ws: could not subscribe to broker: " + err.Error())
+		}
+	}
+	return m
 }
 
 var ErrUnknownHandler = errors.New("ws: unknown handler")
 var ErrInvalidPacket = errors.New("ws: invalid packet")
+var ErrUnknownConnection = errors.New("ws: unknown connection")
 
-type handler func(r *Request) error
+// HandlerFunc handles request with payload already decoded by RegisterHandler
+type HandlerFunc func(r *Request) error
 
 // Hook is function called before main loop or after connection was closed
 type Hook func(conn *Connection)
 
-// Connection holds all data and websocket connection
+// Connection holds all data and transport of the client, usually websocket connection
 type Connection struct {
-	l    sync.Mutex
-	conn *websocket.Conn
-	ctx  context.Context
+	transport transport
+	ctx       context.Context
+	codec     codec
+
+	id        string
+	principal string
+	ws        *ws
+	rooms     map[string]struct{}
+
+	sl    sync.Mutex
+	state map[interface{}]stateEntry
+	// stateClosed is set when connection is closed, so later values are removed right away
+	stateClosed bool
+
+	ol           sync.Mutex
+	nextObserver int
+	observers    map[int]func(Frame)
+
+	cl       sync.Mutex
+	closeErr *websocket.CloseError
+
+	subl   sync.Mutex
+	subs   map[string]*Subscription
+	subSeq int64
+	// subsClosed is set when connection is closed, so later subscriptions are canceled
+	subsClosed bool
+
+	ul      sync.Mutex
+	uploads map[int64]*upload
+
+	seql   sync.Mutex
+	seqs   map[string]*sequence
+	outbox *outbox
 
+	traffic      trafficCounter
+	quotaWindows []quotaWindow
+
+	connectedAt time.Time
+	lastMessage int64
+	queued      int64
+
+	// Request is the request which opened the connection,
+	// for long-polling sessions it is already finished, so its Context is canceled
 	Request *http.Request
-	Values  sync.Map
+	// Values holds arbitrary connection data
+	//
+	// Deprecated: use Key, which is typed and supports cleanup when connection closes
+	Values sync.Map
+}
+
+func (m *ws) newConnection(ctx context.Context, r *http.Request, t transport, c codec) *Connection {
+	conn := &Connection{
+		transport: t,
+		ctx:       ctx,
+		codec:     c,
+
+		id:    random.Hex(16),
+		ws:    m,
+		rooms: make(map[string]struct{}),
+
+		connectedAt: time.Now(),
+
+		Request: r,
+	}
+	if m.principal != nil {
+		conn.principal = m.principal(r)
+	}
+	return conn
 }
 
 func (conn *Connection) Context() context.Context {
 	return conn.ctx
 }
 
+// ID returns identifier unique across instances, usable with WS.SendTo
+func (conn *Connection) ID() string {
+	return conn.id
+}
+
+// Principal returns principal resolved by WithPrincipal or empty string
+func (conn *Connection) Principal() string {
+	return conn.principal
+}
+
+// Join adds connection to the room
+func (conn *Connection) Join(room string) {
+	conn.ws.join(conn, room)
+}
+
+// Leave removes connection from the room
+func (conn *Connection) Leave(room string) {
+	conn.ws.leave(conn, room)
+}
+
+// Rooms returns rooms which connection joined
+func (conn *Connection) Rooms() []string {
+	conn.ws.mu.RLock()
+	defer conn.ws.mu.RUnlock()
+
+	rooms := make([]string, 0, len(conn.rooms))
+	for room := range conn.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// response is frame sent from server to client
+type response struct {
+	ID    int64
+	Topic string
+	Data  interface{}
+	Error *Error `json:",omitempty"`
+
+	// Seq numbers pushes of Stream, see WithSequences
+	Seq    int64  `json:",omitempty"`
+	Stream string `json:",omitempty"`
+
+	rawID json.RawMessage
+	err   error
+}
+
 func (conn *Connection) Send(id int64, topic string, data interface{}) error {
-	conn.l.Lock()
-	defer conn.l.Unlock()
-	return conn.conn.WriteJSON(struct {
-		ID    int64
-		Topic string
-		Data  interface{}
-	}{id, topic, data})
+	if s := conn.topicSequence(topic); s != nil && id == 0 {
+		return s.send(topic, response{Topic: topic, Data: data}, conn.send)
+	}
+	return conn.send(response{ID: id, Topic: topic, Data: data})
+}
+
+func (conn *Connection) send(resp response) error {
+	b, err := json.Marshal(conn.codec.encode(resp))
+	if err != nil {
+		return err
+	}
+	return conn.write(resp.Topic, b)
+}
+
+func (conn *Connection) sendBatch(resps []response) error {
+	encoded := make([]interface{}, 0, len(resps))
+	for _, resp := range resps {
+		encoded = append(encoded, conn.codec.encode(resp))
+	}
+	b, err := json.Marshal(encoded)
+	if err != nil {
+		return err
+	}
+	return conn.write("", b)
+}
+
+func (conn *Connection) write(topic string, b []byte) error {
+	if !conn.ws.account(conn, Outbound, len(b)) {
+		return ErrQuotaExceeded
+	}
+	conn.observe(Outbound, b)
+
+	atomic.AddInt64(&conn.queued, int64(len(b)))
+	defer atomic.AddInt64(&conn.queued, -int64(len(b)))
+	return conn.transport.write(topic, b)
 }
 
 // Request represents data sends from client to server
@@ -75,34 +296,128 @@ type Request struct {
 	ID    int64
 	Topic string
 	Data  json.RawMessage
+
+	// IdempotencyKey identifies retries of the request, see Dedup
+	IdempotencyKey string `json:",omitempty"`
+
+	params map[string]string
+	tap    func(resp response)
+
+	rawID        json.RawMessage
+	notification bool
+	batch        *batch
+	err          error
+}
+
+// Param returns value of {name} segment of the topic pattern,
+// "*" returns segments matched by trailing wildcard
+func (r *Request) Param(name string) string {
+	return r.params[name]
 }
 
 // Respond sends response to client and returns error if failed
 func (r *Request) Respond(data interface{}) error {
-	return r.C.Send(r.ID, r.Topic, data)
+	return r.reply(response{ID: r.ID, Topic: r.Topic, Data: data})
+}
+
+// reply sends response to the request, responses to notifications are dropped
+// and responses to batched requests are collected until the batch is handled
+func (r *Request) reply(resp response) error {
+	if r.notification {
+		return nil
+	}
+	if r.tap != nil {
+		r.tap(resp)
+	}
+	resp.rawID = r.rawID
+	if r.batch != nil && r.batch.collect(resp) {
+		return nil
+	}
+	return r.C.send(resp)
 }
 
 type ws struct {
 	ErrorHandler func(c *Connection, err error)
 	Upgrader     *websocket.Upgrader
 
-	handlers  map[string]handler
-	preHooks  []Hook
-	postHooks []Hook
+	root       *group
+	routes     routeTable
+	preHooks   []Hook
+	postHooks  []Hook
+	lifecycles []Lifecycle
+
+	id           string
+	broker       Broker
+	principal    func(r *http.Request) string
+	admission    *admission
+	compression  *compression
+	fallbackOpts HTTPFallbackOptions
+	batchOpts    BatchOptions
+
+	stats     topicStats
+	quotas    *quotas
+	sequences *sequences
+
+	trafficOpts      TrafficOptions
+	tl               sync.Mutex
+	principalTraffic map[string]*trafficCounter
+	trafficSwept     time.Time
+
+	mu       sync.RWMutex
+	conns    map[string]*Connection
+	rooms    map[string]map[*Connection]struct{}
+	channels map[string]map[*Subscription]struct{}
+	sessions map[string]*session
 }
 
 var rTest = reflect.TypeOf(&Request{})
+var errType = reflect.TypeOf((*error)(nil)).Elem()
 
 // RegisterHandler register function for entered topic
 // note that handlerFn is interface{} but should be function (func(r *Request, optionalParameter string))
+// optionally returning error, which is sent to client, other results are ignored
 func (m *ws) RegisterHandler(topic string, handlerFn interface{}) {
-	if m.handlers == nil {
-		m.handlers = make(map[string]handler)
-	}
-	if _, ok := m.handlers[topic]; ok {
-		panic("topic already registered")
-	}
+	m.root.RegisterHandler(topic, handlerFn)
+}
+
+func (m *ws) handle(topic string, handlerFn interface{}, replace bool) {
+	m.root.handle(topic, handlerFn, replace)
+}
+
+// setOutput records type of response data of handler registered for topic
+func (m *ws) setOutput(topic string, out reflect.Type) {
+	m.root.setOutput(topic, out)
+}
+
+// ReplaceHandler registers handlerFn for topic, replacing already registered handler
+func (m *ws) ReplaceHandler(topic string, handlerFn interface{}) {
+	m.root.ReplaceHandler(topic, handlerFn)
+}
+
+// UnregisterHandler removes handler of topic
+func (m *ws) UnregisterHandler(topic string) {
+	m.root.UnregisterHandler(topic)
+}
 
+// Use appends middleware wrapping every handler
+func (m *ws) Use(mw ...Middleware) {
+	m.root.Use(mw...)
+}
+
+// Group returns router registering handlers under topic prefix
+func (m *ws) Group(prefix string, mw ...Middleware) Router {
+	return m.root.Group(prefix, mw...)
+}
+
+// SetFallback registers function called for topics without handler
+func (m *ws) SetFallback(handlerFn interface{}) {
+	h := newHandler(handlerFn)
+	m.routes.update(func(rs *routes) {
+		rs.fallback = route{pattern: fallbackTopic, handler: rs.chain(m.root, h), base: h, group: m.root}
+	})
+}
+
+func newHandler(handlerFn interface{}) HandlerFunc {
 	fn := reflect.TypeOf(handlerFn)
 	if fn.Kind() != reflect.Func || fn.NumIn() < 1 || fn.NumIn() > 2 {
 		panic("handler not function")
@@ -112,6 +427,8 @@ func (m *ws) RegisterHandler(topic string, handlerFn interface{}) {
 	if arg1 != rTest {
 		panic("handler is invalid")
 	}
+	// only single error result is sent to client, other results are ignored
+	returnsErr := fn.NumOut() == 1 && fn.Out(0) == errType
 
 	var in []reflect.Type
 	in = append(in, arg1)
@@ -121,7 +438,7 @@ func (m *ws) RegisterHandler(topic string, handlerFn interface{}) {
 		in = append(in, arg2)
 	}
 
-	m.handlers[topic] = func(r *Request) error {
+	return func(r *Request) error {
 		var toFn []reflect.Value
 		toFn = append(toFn, reflect.ValueOf(r))
 
@@ -130,13 +447,16 @@ func (m *ws) RegisterHandler(topic string, handlerFn interface{}) {
 
 			err := json.Unmarshal(r.Data, dataValue.Interface())
 			if err != nil {
-				return err
+				return &Error{Code: CodeInvalidPayload, Message: err.Error()}
 			}
 
 			toFn = append(toFn, dataValue.Elem())
 		}
-		reflect.ValueOf(handlerFn).Call(toFn)
+		out := reflect.ValueOf(handlerFn).Call(toFn)
 
+		if returnsErr && !out[0].IsNil() {
+			return out[0].Interface().(error)
+		}
 		return nil
 	}
 }
@@ -151,12 +471,44 @@ func (m *ws) AddPostHook(hook Hook) {
 	m.postHooks = append(m.postHooks, hook)
 }
 
+// ServeHTTP serves websocket endpoint using Native protocol
 func (m *ws) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.serveWS(w, r, m.codec(Native))
+}
+
+// Handler returns websocket endpoint using the protocol
+func (m *ws) Handler(p Protocol) http.Handler {
+	return endpoint{m: m, codec: m.codec(p)}
+}
+
+type endpoint struct {
+	m     *ws
+	codec codec
+}
+
+func (e endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.m.serveWS(w, r, e.codec)
+}
+
+func (m *ws) serveWS(w http.ResponseWriter, r *http.Request, c codec) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	release, status := m.admit(r)
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	defer release()
+
+	var cw *countingWriter
+	if m.compression != nil {
+		cw = &countingWriter{ResponseWriter: w}
+		w = cw
+	}
+
 	ws, err := m.Upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		m.ErrorHandler(nil, fmt.Errorf("error: %w = could not upgrade connection", err))
@@ -167,48 +519,150 @@ func (m *ws) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
-	conn := &Connection{
-		conn: ws,
-		ctx:  ctx,
+	t := &wsTransport{conn: ws, compression: m.compression}
+	if m.compression != nil {
+		t.compressed = negotiated(r)
+		t.wire = cw.conn
+		if m.compression.opts.Level != 0 {
+			// level is checked by WithCompression
+			ws.SetCompressionLevel(m.compression.opts.Level)
+		}
+	}
 
-		Request: r,
+	conn := m.newConnection(ctx, r, t, c)
+	m.run(conn, func() ([]byte, error) {
+		for {
+			kind, frame, err := ws.ReadMessage()
+			if err != nil || kind != websocket.BinaryMessage {
+				return frame, err
+			}
+			if err := m.handleBinary(conn, frame); err != nil {
+				return nil, err
+			}
+		}
+	})
+}
+
+// run serves connection until read fails or invalid frame is received
+func (m *ws) run(conn *Connection, read func() ([]byte, error)) {
+	defer conn.clearState()
+	if !m.connect(conn) {
+		return
 	}
 
+	m.register(conn)
+	defer m.unregister(conn)
+
 	for _, hook := range m.preHooks {
 		hook(conn)
 	}
 
+	var readErr error
 	for {
-		var message Request
-		readErr := ws.ReadJSON(&message)
+		var frame []byte
+		frame, readErr = read()
 		if readErr != nil {
-			if websocket.IsUnexpectedCloseError(readErr) {
-				m.ErrorHandler(conn, fmt.Errorf("error: %w = socket closed", readErr))
+			if websocket.IsUnexpectedCloseError(readErr) && !conn.closedByServer() {
+				m.reportError(conn, fmt.Errorf("error: %w = socket closed", readErr))
 			}
 			break
 		}
 
-		if message.ID == 0 || message.Topic == "" {
-			m.ErrorHandler(conn, ErrInvalidPacket)
+		atomic.StoreInt64(&conn.lastMessage, time.Now().UnixNano())
+		if !m.account(conn, Inbound, len(frame)) {
+			break
+		}
+		conn.observe(Inbound, frame)
+		for _, l := range m.lifecycles {
+			l.OnMessage(conn, frame)
+		}
+		if !m.handleFrame(conn, frame) {
+			conn.Close(websocket.CloseInvalidFramePayloadData, "invalid packet")
 			break
 		}
+	}
+
+	for _, hook := range m.postHooks {
+		hook(conn)
+	}
+
+	code, reason, err := conn.closeStatus(readErr)
+	for _, l := range m.lifecycles {
+		l.OnClose(conn, code, reason, err)
+	}
+}
+
+// handleFrame dispatches requests decoded from the frame
+// and returns false when connection should be closed
+func (m *ws) handleFrame(conn *Connection, frame []byte) bool {
+	reqs, isBatch := conn.codec.decode(frame)
 
-		handlerFn, ok := m.handlers[message.Topic]
-		if !ok {
-			m.ErrorHandler(conn, fmt.Errorf("%w: %s", ErrUnknownHandler, message.Topic))
+	var b *batch
+	if isBatch {
+		b = &batch{}
+	}
+
+	concurrency := m.batchOpts.Concurrency
+	if len(reqs) < 2 || concurrency < 2 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	keep := true
+	for _, req := range reqs {
+		req.C = conn
+		req.batch = b
+
+		if req.err != nil {
+			m.reportError(conn, req.err)
+			req.Fail(req.err)
+			keep = keep && !conn.codec.fatal(req.err)
+			continue
+		}
+		if concurrency == 1 {
+			m.dispatch(req)
 			continue
 		}
 
-		message.C = conn
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(req *Request) {
+			defer func() { <-sem; wg.Done() }()
+			m.dispatch(req)
+		}(req)
+	}
+	wg.Wait()
 
-		handlerErr := handlerFn(&message)
-		if handlerErr != nil {
-			m.ErrorHandler(conn, fmt.Errorf("error: %w in handler: %s", err, message.Topic))
-			break
+	if b != nil {
+		if resps := b.close(); len(resps) > 0 {
+			if err := conn.sendBatch(resps); err != nil {
+				m.reportError(conn, err)
+			}
 		}
 	}
+	return keep
+}
 
-	for _, hook := range m.postHooks {
-		hook(conn)
+func (m *ws) dispatch(req *Request) {
+	rs := m.routes.load()
+	rt, params, ok := rs.match(req.Topic)
+	if !ok && rs.fallback.handler != nil {
+		rt, ok = rs.fallback, true
+	}
+	if !ok {
+		unknownErr := fmt.Errorf("%w: %s", ErrUnknownHandler, req.Topic)
+		m.reportError(req.C, unknownErr)
+		req.Fail(unknownErr)
+		return
+	}
+	req.params = params
+
+	start := time.Now()
+	handlerErr := rt.handler(req)
+	m.stats.record(rt.pattern, time.Since(start), handlerErr)
+	if handlerErr != nil {
+		m.reportError(req.C, fmt.Errorf("error: %w in handler: %s", handlerErr, req.Topic))
+		req.Fail(handlerErr)
 	}
 }
@@ -60,6 +60,12 @@ type packet struct {
 	Data  interface{}
 }
 
+type errorPacket struct {
+	Id    int64
+	Topic string
+	Error *ws.Error
+}
+
 type expected struct {
 	packet
 	Data json.RawMessage
@@ -125,9 +131,9 @@ func Test(t *testing.T) {
 
 		conn.WriteJSON(packet{1, "testString", 1})
 
-		var resp packet
+		var resp errorPacket
 		err = conn.ReadJSON(&resp)
-		if err == nil {
+		if err != nil || resp.Id != 1 || resp.Error == nil || resp.Error.Code != "invalid_payload" {
 			t.Fail()
 		}
 	})
@@ -141,10 +147,10 @@ func Test(t *testing.T) {
 		}
 		defer conn.Close()
 
-		var resp packet
+		var resp errorPacket
 		conn.WriteJSON(packet{1, "testStruct", testStructBad{1}})
 		err = conn.ReadJSON(&resp)
-		if err == nil {
+		if err != nil || resp.Id != 1 || resp.Error == nil || resp.Error.Code != "invalid_payload" {
 			t.Fail()
 		}
 	})
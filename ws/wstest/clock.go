@@ -0,0 +1,107 @@
+package wstest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/IAmRadek/go-kit/ws"
+)
+
+// Clock is fake ws.Clock which moves only when advanced
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*ticker
+	added   chan struct{}
+}
+
+var _ ws.Clock = (*Clock)(nil)
+
+// NewClock returns Clock set to now
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now, added: make(chan struct{}, 1)}
+}
+
+// Now returns current fake time
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker returns ticker firing when clock is advanced past its interval
+func (c *Clock) NewTicker(d time.Duration) ws.Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &ticker{clock: c, interval: d, next: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.tickers = append(c.tickers, t)
+
+	select {
+	case c.added <- struct{}{}:
+	default:
+	}
+	return t
+}
+
+// Advance moves clock forward and fires due tickers,
+// like time.Ticker it drops ticks for slow receivers
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		if t.next.After(c.now) {
+			continue
+		}
+		for !t.next.After(c.now) {
+			t.next = t.next.Add(t.interval)
+		}
+		select {
+		case t.c <- c.now:
+		default:
+		}
+	}
+}
+
+// WaitTickers blocks until at least n tickers are running,
+// e.g. until hooks of the connection started
+func (c *Clock) WaitTickers(n int) {
+	for {
+		c.mu.Lock()
+		running := len(c.tickers)
+		c.mu.Unlock()
+		if running >= n {
+			return
+		}
+		<-c.added
+	}
+}
+
+func (c *Clock) remove(t *ticker) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, other := range c.tickers {
+		if other == t {
+			c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+			return
+		}
+	}
+}
+
+type ticker struct {
+	clock    *Clock
+	interval time.Duration
+	next     time.Time
+	c        chan time.Time
+}
+
+func (t *ticker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *ticker) Stop() {
+	t.clock.remove(t)
+}
@@ -0,0 +1,72 @@
+package wstest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/hooks/record"
+)
+
+// Replay sends inbound frames of captured session to h in recorded order
+// and returns transcript of the replayed session.
+//
+// After each inbound frame Replay waits until as many outbound frames
+// as were recorded before the next inbound frame arrive, or until Timeout,
+// so transcript can be compared with the capture deterministically.
+// Redacted frames are replayed as captured, binary frames are replayed as binary frames.
+func Replay(t testing.TB, h http.Handler, session []record.Entry, opts ...Option) []record.Entry {
+	t.Helper()
+
+	c := Connect(t, h, opts...)
+
+	var transcript []record.Entry
+	collect := func(n int) {
+		ctx, cancel := c.context()
+		defer cancel()
+
+		for i := 0; i < n; i++ {
+			msg, err := c.Client.Next(ctx)
+			if err != nil {
+				return
+			}
+			transcript = append(transcript, record.NewEntry("", ws.Frame{
+				Time:      time.Now(),
+				Direction: ws.Outbound,
+				Data:      msg.Raw,
+				Binary:    msg.Binary,
+			}))
+		}
+	}
+
+	expected := 0
+	for _, e := range session {
+		if e.Direction == ws.Outbound {
+			expected++
+			continue
+		}
+
+		collect(expected)
+		expected = 0
+
+		data, err := e.Data()
+		if err != nil {
+			t.Fatalf("wstest: invalid binary frame: %v", err)
+		}
+		if e.Binary {
+			c.RawBinary(data)
+		} else {
+			c.Raw(data)
+		}
+		transcript = append(transcript, record.NewEntry("", ws.Frame{
+			Time:      time.Now(),
+			Direction: ws.Inbound,
+			Data:      data,
+			Binary:    e.Binary,
+		}))
+	}
+	collect(expected)
+
+	return transcript
+}
@@ -0,0 +1,183 @@
+// Package wstest provides in-process client for unit testing ws handlers and hooks.
+//
+// Client talks to WS over in-memory connection, no real sockets are opened.
+package wstest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/IAmRadek/go-kit/ws/client"
+	"github.com/gorilla/websocket"
+	memws "github.com/posener/wstest"
+)
+
+// Timeout is default time Client waits for responses, pushes and close
+var Timeout = time.Second
+
+// Option configures Client
+type Option func(o *options)
+
+type options struct {
+	url    string
+	header http.Header
+}
+
+// WithHeader sets headers of the upgrade request, e.g. authorization
+func WithHeader(header http.Header) Option {
+	return func(o *options) {
+		o.header = header
+	}
+}
+
+// WithURL sets url of the upgrade request, e.g. with query parameters
+func WithURL(url string) Option {
+	return func(o *options) {
+		o.url = url
+	}
+}
+
+// Client is connected to WS and fails the test on unexpected traffic
+type Client struct {
+	*client.Client
+
+	t       testing.TB
+	timeout time.Duration
+}
+
+// Connect connects new client to h, usually ws.WS, and closes it at the end of the test
+func Connect(t testing.TB, h http.Handler, opts ...Option) *Client {
+	t.Helper()
+
+	o := options{url: "ws://example.org/websocket"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	conn, _, err := memws.NewDialer(h).Dial(o.url, o.header)
+	if err != nil {
+		t.Fatalf("wstest: could not connect: %v", err)
+	}
+
+	c := &Client{
+		Client:  client.New(conn),
+		t:       t,
+		timeout: Timeout,
+	}
+	t.Cleanup(func() { c.Client.Close() })
+
+	return c
+}
+
+func (c *Client) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), c.timeout)
+}
+
+// Call sends request and decodes response into out, failing the test on error
+func (c *Client) Call(topic string, payload interface{}, out interface{}) {
+	c.t.Helper()
+
+	if err := c.TryCall(topic, payload, out); err != nil {
+		c.t.Fatalf("wstest: call %s: %v", topic, err)
+	}
+}
+
+// TryCall sends request and decodes response into out
+func (c *Client) TryCall(topic string, payload interface{}, out interface{}) error {
+	ctx, cancel := c.context()
+	defer cancel()
+	return c.Client.Call(ctx, topic, payload, out)
+}
+
+// Send sends request without waiting for response
+func (c *Client) Send(topic string, payload interface{}) int64 {
+	c.t.Helper()
+
+	id, err := c.Client.Send(topic, payload)
+	if err != nil {
+		c.t.Fatalf("wstest: send %s: %v", topic, err)
+	}
+	return id
+}
+
+// Next returns next push, failing the test if none arrived in time
+func (c *Client) Next() client.Message {
+	c.t.Helper()
+
+	ctx, cancel := c.context()
+	defer cancel()
+
+	msg, err := c.Client.Next(ctx)
+	if err != nil {
+		c.t.Fatalf("wstest: no message received: %v", err)
+	}
+	return msg
+}
+
+// ExpectPush waits for next push, checks its topic and decodes it into out
+func (c *Client) ExpectPush(topic string, out interface{}) {
+	c.t.Helper()
+
+	msg := c.Next()
+	if msg.Topic != topic {
+		c.t.Fatalf("wstest: got push %s %s; want topic %s", msg.Topic, msg.Data, topic)
+	}
+	if err := msg.Decode(out); err != nil {
+		c.t.Fatalf("wstest: could not decode push %s: %v", topic, err)
+	}
+}
+
+// ExpectNoPush fails the test if any push arrives within d
+func (c *Client) ExpectNoPush(d time.Duration) {
+	c.t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	msg, err := c.Client.Next(ctx)
+	if err == nil {
+		c.t.Fatalf("wstest: got unexpected push %s %s", msg.Topic, msg.Data)
+	}
+}
+
+// ExpectClose waits for server to close the connection with the close code
+func (c *Client) ExpectClose(code int) {
+	c.t.Helper()
+
+	select {
+	case <-c.Done():
+	case <-time.After(c.timeout):
+		c.t.Fatalf("wstest: connection not closed")
+	}
+
+	got := websocket.CloseAbnormalClosure
+	var closeErr *websocket.CloseError
+	if errors.As(c.Err(), &closeErr) {
+		got = closeErr.Code
+	}
+	if got != code {
+		c.t.Fatalf("wstest: connection closed with code %d; want %d", got, code)
+	}
+}
+
+// Raw sends raw frame, e.g. to test handling of malformed packets
+func (c *Client) Raw(frame json.RawMessage) {
+	c.t.Helper()
+
+	if err := c.SendRaw(frame); err != nil {
+		c.t.Fatalf("wstest: could not write frame: %v", err)
+	}
+}
+
+// RawBinary sends raw binary frame, see ws.BinaryFrame
+func (c *Client) RawBinary(frame []byte) {
+	c.t.Helper()
+
+	if err := c.SendRawBinary(frame); err != nil {
+		c.t.Fatalf("wstest: could not write frame: %v", err)
+	}
+}
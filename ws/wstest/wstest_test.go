@@ -0,0 +1,77 @@
+package wstest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IAmRadek/go-kit/ws"
+	"github.com/IAmRadek/go-kit/ws/hooks/ping"
+	"github.com/IAmRadek/go-kit/ws/wstest"
+)
+
+func TestCall(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	w.RegisterHandler("echo", func(r *ws.Request, text string) {
+		r.Respond(text)
+	})
+
+	c := wstest.Connect(t, w)
+
+	var resp string
+	c.Call("echo", "hello", &resp)
+	if resp != "hello" {
+		t.Errorf("echo = %q; want hello", resp)
+	}
+}
+
+func TestPingWithFakeClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := wstest.NewClock(start)
+
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	w.AddPreHook(ping.New(ping.WithInterval(time.Minute), ping.WithClock(clock)))
+
+	c := wstest.Connect(t, w)
+	clock.WaitTickers(1)
+
+	clock.Advance(30 * time.Second)
+	c.ExpectNoPush(10 * time.Millisecond)
+
+	clock.Advance(30 * time.Second)
+	var nano int64
+	c.ExpectPush(ping.Topic, &nano)
+	if want := start.Add(time.Minute).UnixNano(); nano != want {
+		t.Errorf("pong = %d; want %d", nano, want)
+	}
+}
+
+func TestPushBuffer(t *testing.T) {
+	w := ws.NewWS(nil, func(connection *ws.Connection, err error) {})
+	w.RegisterHandler("pushes", func(r *ws.Request, n int) {
+		for i := 1; i <= n; i++ {
+			r.C.Send(0, "push", i)
+		}
+		r.Respond(n)
+	})
+
+	c := wstest.Connect(t, w)
+	c.SetPushBuffer(2)
+	c.Call("pushes", 3, nil)
+	if dropped := c.Dropped(); dropped != 1 {
+		t.Errorf("dropped %d pushes; want 1", dropped)
+	}
+	for _, want := range []int{2, 3} {
+		var got int
+		c.ExpectPush("push", &got)
+		if got != want {
+			t.Errorf("push %d; want %d", got, want)
+		}
+	}
+
+	c.SetPushBuffer(0)
+	c.Call("pushes", 2, nil)
+	c.ExpectNoPush(50 * time.Millisecond)
+	if dropped := c.Dropped(); dropped != 3 {
+		t.Errorf("dropped %d pushes; want 3", dropped)
+	}
+}